@@ -22,6 +22,65 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// ConditionTypeReady indicates that all the roots and secondary roots were calculated and synced
+	ConditionTypeReady = "Ready"
+	// ConditionTypeSynced indicates that the calculated quotas were written to all the roots and secondary roots
+	ConditionTypeSynced = "Synced"
+	// ConditionTypeDegraded indicates that one or more of the roots or secondary roots failed to be calculated or synced
+	ConditionTypeDegraded = "Degraded"
+	// ConditionTypeReservationsActive indicates that resources of removed nodes are reserved for one or more node groups
+	ConditionTypeReservationsActive = "ReservationsActive"
+	// ConditionTypeShrinkBlocked indicates that the quota of one or more secondary roots was not shrunk below what
+	// their child subnamespaces were allocated
+	ConditionTypeShrinkBlocked = "ShrinkBlocked"
+	// ConditionTypeRecalculationPending indicates that the nodes of the node groups changed, and the quotas are
+	// recalculated once the nodes are stable for the settle window
+	ConditionTypeRecalculationPending = "RecalculationPending"
+	// ConditionTypeNodesOverlapping indicates that nodes are selected by more than one node group, in this NodeQuotaConfig
+	// or in other NodeQuotaConfigs, so their resources may be counted more than once
+	ConditionTypeNodesOverlapping = "NodesOverlapping"
+	// ConditionTypeConflicted indicates that roots or secondary roots of the NodeQuotaConfig are managed by an older
+	// NodeQuotaConfig, so this NodeQuotaConfig doesn't write any quota
+	ConditionTypeConflicted = "Conflicted"
+	// ConditionTypeFieldsConflicted indicates that controlled resources of the quotas of roots or secondary roots are owned
+	// by other field managers, so they weren't written
+	ConditionTypeFieldsConflicted = "FieldsConflicted"
+)
+
+const (
+	// ReleaseReservationsAnnotation requests to release reservations immediately.
+	// Its value is a comma separated list of node groups, or of nodeGroup/nodeName to release the reservation of a single node
+	ReleaseReservationsAnnotation = "dana.hns.io/release-reservations"
+	// ExtendReservationsAnnotation requests to extend reservations by a duration.
+	// Its value is a comma separated list of nodeGroup=duration or nodeGroup/nodeName=duration, e.g. gpu=12h
+	ExtendReservationsAnnotation = "dana.hns.io/extend-reservations"
+	// UnmanagedAnnotation is set on the root ResourceQuotas and secondary root Subnamespaces which were orphaned by a deleted
+	// NodeQuotaConfig, its value is the namespace/name of the NodeQuotaConfig which managed them
+	UnmanagedAnnotation = "dana.hns.io/unmanaged-by-nodequotaconfig"
+	// PausedAnnotation pauses the writes of all the quotas of the NodeQuotaConfig when it is set to true,
+	// the quotas are still calculated and the planned changes are shown in the status
+	PausedAnnotation = "dana.hns.io/paused"
+	// PausedNodeGroupsAnnotation pauses the writes of the quotas of some node groups of the NodeQuotaConfig.
+	// Its value is a comma separated list of node groups, e.g. gpu,cpu
+	PausedNodeGroupsAnnotation = "dana.hns.io/paused-node-groups"
+	// NodeQuotaConfigFinalizer is the finalizer which applies the deletion policy of a NodeQuotaConfig before it is deleted
+	NodeQuotaConfigFinalizer = "dana.hns.io/nodequotaconfig-finalizer"
+	// ReservationRequesterAnnotation is set by the webhook to the user who requested to release or extend reservations
+	ReservationRequesterAnnotation = "dana.hns.io/reservation-requester"
+)
+
+// ReservationAction is an action that can be requested on reservations
+// +kubebuilder:validation:Enum=Release;Extend
+type ReservationAction string
+
+const (
+	// ReservationActionRelease releases reservations immediately
+	ReservationActionRelease ReservationAction = "Release"
+	// ReservationActionExtend extends reservations by a duration
+	ReservationActionExtend ReservationAction = "Extend"
+)
+
 // NodeQuotaConfigSpec defines the desired state of NodeQuotaConfig
 type NodeQuotaConfigSpec struct {
 	// ReservedHoursToLive defines how many hours the ReservedResources can live until they are removed from the cluster resources
@@ -33,16 +92,141 @@ type NodeQuotaConfigSpec struct {
 
 	// Roots defines the state of the cluster's secondary roots and roots
 	Roots []SubnamespacesRoots `json:"subnamespacesRoots"`
+
+	// Mode defines whether the calculated quotas are written to the roots and secondary roots (Enforce),
+	// or only shown in the status along with the planned changes (DryRun)
+	// +kubebuilder:validation:Enum=Enforce;DryRun
+	// +kubebuilder:default=Enforce
+	// +optional
+	Mode ConfigMode `json:"mode,omitempty"`
+
+	// ShrinkPolicy defines what happens when the calculated quota of a secondary root is lower than its current quota
+	// and than the sum of the quotas allocated to its child subnamespaces: the shrink is refused (Refuse),
+	// the quota is shrunk only down to the allocated sum (Clamp), or the quota is shrunk anyway (Proceed)
+	// +kubebuilder:validation:Enum=Refuse;Clamp;Proceed
+	// +kubebuilder:default=Clamp
+	// +optional
+	ShrinkPolicy ShrinkPolicy `json:"shrinkPolicy,omitempty"`
+
+	// SettleWindow defines how long the nodes of the node groups must be stable after they changed before the quotas
+	// are recalculated, so the changes of many nodes, such as during a rolling upgrade, are handled together.
+	// The quotas are recalculated on every change by default
+	// Possible values examples: "2m", "10m"
+	// +optional
+	SettleWindow *metav1.Duration `json:"settleWindow,omitempty"`
+
+	// OverlapPolicy defines how nodes which are selected by more than one node group of the NodeQuotaConfig are counted:
+	// in every node group which selects them (Report), or only in the node group with the highest priority (Priority)
+	// +kubebuilder:validation:Enum=Report;Priority
+	// +kubebuilder:default=Report
+	// +optional
+	OverlapPolicy OverlapPolicy `json:"overlapPolicy,omitempty"`
+
+	// FieldConflictPolicy defines how controlled resources of the quotas which are owned by other field managers are handled:
+	// they aren't written and the conflict is reported in the status (Report), or their ownership is taken over (Force)
+	// +kubebuilder:validation:Enum=Report;Force
+	// +kubebuilder:default=Report
+	// +optional
+	FieldConflictPolicy FieldConflictPolicy `json:"fieldConflictPolicy,omitempty"`
+
+	// DeletionPolicy defines what happens to the quotas when the NodeQuotaConfig is deleted: they are left as they are
+	// and annotated as unmanaged (Orphan), or the quotas from before the NodeQuotaConfig managed them are written back (Restore)
+	// +kubebuilder:validation:Enum=Orphan;Restore
+	// +kubebuilder:default=Orphan
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
-// ReservedResources shows the resources of nodes that were deleted from the cluster but not from the subnamespace quota
+// DeletionPolicy defines what happens to the quotas when a NodeQuotaConfig is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyOrphan leaves the quotas as they are and annotates them as unmanaged
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyRestore writes back the quotas from before the NodeQuotaConfig managed them
+	DeletionPolicyRestore DeletionPolicy = "Restore"
+)
+
+// FieldConflictPolicy defines how controlled resources of the quotas which are owned by other field managers are handled
+type FieldConflictPolicy string
+
+const (
+	// FieldConflictPolicyReport doesn't write the conflicting resources and reports the conflict
+	FieldConflictPolicyReport FieldConflictPolicy = "Report"
+	// FieldConflictPolicyForce takes over the ownership of the conflicting resources and writes them
+	FieldConflictPolicyForce FieldConflictPolicy = "Force"
+)
+
+// OverlapPolicy defines how nodes which are selected by more than one node group are counted
+type OverlapPolicy string
+
+const (
+	// OverlapPolicyReport counts the nodes in every node group which selects them, and only reports the overlap
+	OverlapPolicyReport OverlapPolicy = "Report"
+	// OverlapPolicyPriority counts the nodes only in the node group with the highest priority which selects them
+	OverlapPolicyPriority OverlapPolicy = "Priority"
+)
+
+// ShrinkPolicy defines how a shrink of a secondary root quota below what its children were allocated is handled
+type ShrinkPolicy string
+
+const (
+	// ShrinkPolicyRefuse keeps the current quota of the resources which would be shrunk below the allocated sum
+	ShrinkPolicyRefuse ShrinkPolicy = "Refuse"
+	// ShrinkPolicyClamp shrinks the quota of the resources only down to the allocated sum
+	ShrinkPolicyClamp ShrinkPolicy = "Clamp"
+	// ShrinkPolicyProceed shrinks the quota even if it is below the allocated sum
+	ShrinkPolicyProceed ShrinkPolicy = "Proceed"
+)
+
+// ConfigMode defines whether a NodeQuotaConfig writes the calculated quotas
+type ConfigMode string
+
+const (
+	// ConfigModeEnforce writes the calculated quotas to the roots and secondary roots
+	ConfigModeEnforce ConfigMode = "Enforce"
+	// ConfigModeDryRun only shows the calculated quotas and the planned changes in the status
+	ConfigModeDryRun ConfigMode = "DryRun"
+)
+
+// ReservedResources shows the resources of a node that was deleted from the cluster but not from the subnamespace quota
 type ReservedResources struct {
-	// Resources defines the number of resources of the nodes
+	// Resources defines the number of resources of the node
 	Resources corev1.ResourceList `json:"resources,omitempty"`
-	// NodeGroup defines which of the secondaryRoots the nodes that were removed was a part of
+	// NodeGroup defines which of the secondaryRoots the node that was removed was a part of
 	NodeGroup string `json:"nodeGroup,omitempty"`
-	// Timestamp defines when the nodes were removed
+	// NodeName defines the name of the node that was removed.
+	// It is empty when the resources could not be attributed to a specific node, e.g. when the allocatable resources
+	// of the node group decreased without a node being removed
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+	// Timestamp defines when the node was removed
 	Timestamp metav1.Time `json:"Timestamp,omitempty" protobuf:"bytes,8,opt,name=Timestamp"`
+	// ExpirationTime defines when the reservation expires when it was extended,
+	// instead of reservedHoursToLive hours after the Timestamp
+	// +optional
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+}
+
+// ReservationRequest records a manual request to release or extend reservations
+type ReservationRequest struct {
+	// Action is the requested action
+	Action ReservationAction `json:"action"`
+	// NodeGroup is the node group whose reservations were requested
+	NodeGroup string `json:"nodeGroup"`
+	// NodeName limits the request to the reservation of a single node
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+	// Duration is the duration the reservations were extended by
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// RequestedBy is the user who requested the action
+	// +optional
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// RequestTime defines when the request was handled
+	RequestTime metav1.Time `json:"requestTime"`
+	// Reservations is the number of reservations the request was applied to
+	Reservations int `json:"reservations"`
 }
 
 // SubnamespacesRoots define the root and secondary root of the cluster's hierarchy
@@ -57,24 +241,280 @@ type SubnamespacesRoots struct {
 type NodeGroup struct {
 	// LabelSelector defines the label selector of the nodes and how to find them.
 	// Possible values examples: {"app":"gpu-nodes"}
-	LabelSelector map[string]string `json:"labelSelector"`
+	// +optional
+	LabelSelector map[string]string `json:"labelSelector,omitempty"`
+	// NodeSelector defines a label selector of the nodes which supports both matchLabels and matchExpressions.
+	// If both LabelSelector and NodeSelector are set, a node must match both of them.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
 	// Name is the name of the secondaryRoot.
 	Name string `json:"name"`
 	// ResourceMultiplier defines the multiplier that will be used when calculating the resources of nodes for allowing overcommit
 	// Possible values examples: {"cpu":2, "memory":3} {"cpu":3, "gpu":3}
 	ResourceMultiplier map[string]string `json:"multipliers,omitempty"`
+	// RoundingModes defines how the multiplied quantity of every resource is rounded, Floor is used by default.
+	// CPU and quantities with a fractional part are rounded to milli-units, other quantities are rounded to whole units
+	// Possible values examples: {"cpu":"Nearest", "memory":"Ceil"}
+	// +optional
+	RoundingModes map[string]RoundingMode `json:"roundingModes,omitempty"`
 	// ReservedResources resources to be subtracted from each node before addition to secondary roots
 	SystemResourceClaim map[string]resource.Quantity `json:"systemResourceClaim"`
+	// SubtractSystemResourceClaim defines whether the SystemResourceClaim is subtracted from every node before the
+	// multipliers are applied. The claim is only shown in the status by default
+	// +optional
+	SubtractSystemResourceClaim bool `json:"subtractSystemResourceClaim,omitempty"`
+	// Eligibility defines which of the matched nodes are excluded from the capacity of the node group.
+	// The resources of excluded nodes are reserved the same way as the resources of removed nodes
+	// +optional
+	Eligibility *NodeEligibility `json:"eligibility,omitempty"`
+	// Rebalance defines whether the quotas of the direct child subnamespaces of the secondary root are trimmed
+	// when the quota of the secondary root drops below the sum of their quotas, and how the shortfall is divided.
+	// The quotas of the children are kept as they are by default
+	// +optional
+	Rebalance *RebalancePolicy `json:"rebalance,omitempty"`
+	// Priority defines which node group a node is counted in when it is selected by more than one node group
+	// and the overlap policy is Priority. The node group with the highest priority is chosen, and between node groups
+	// with the same priority, the node group which is listed first is chosen
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+	// CatchAll defines that the node group selects the nodes which are not selected by any other node group of the
+	// NodeQuotaConfig, instead of selecting nodes by labels. Only one node group of a NodeQuotaConfig can be a catch-all
+	// +optional
+	CatchAll bool `json:"catchAll,omitempty"`
+}
+
+// UnassignedNodes describes the nodes which are not selected by any node group
+type UnassignedNodes struct {
+	// Nodes is the number of nodes which are not selected by any node group
+	Nodes int `json:"nodes"`
+	// NodeNames are the names of the nodes which are not selected by any node group
+	NodeNames []string `json:"nodeNames,omitempty"`
+	// Allocatable is the sum of the allocatable controlled resources of the nodes
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+}
+
+// NodeOverlap describes a node which is selected by more than one node group
+type NodeOverlap struct {
+	// NodeName is the name of the node
+	NodeName string `json:"nodeName"`
+	// NodeGroups are the node groups of this NodeQuotaConfig which select the node
+	NodeGroups []string `json:"nodeGroups,omitempty"`
+	// OtherConfigs are the node groups of other NodeQuotaConfigs which select the node, as namespace/config/nodeGroup
+	OtherConfigs []string `json:"otherConfigs,omitempty"`
+	// AssignedTo is the only node group the node is counted in, when the overlap policy is Priority
+	AssignedTo string `json:"assignedTo,omitempty"`
+}
+
+// RebalanceStrategy defines how the shortfall of a secondary root is divided between its child subnamespaces
+// +kubebuilder:validation:Enum=Proportional;Weighted
+type RebalanceStrategy string
+
+const (
+	// RebalanceStrategyProportional divides the shortfall proportionally to the current quotas of the children
+	RebalanceStrategyProportional RebalanceStrategy = "Proportional"
+	// RebalanceStrategyWeighted divides the shortfall according to the configured weights of the children
+	RebalanceStrategyWeighted RebalanceStrategy = "Weighted"
+)
+
+// RebalancePolicy defines how the quotas of the child subnamespaces of a secondary root are trimmed
+type RebalancePolicy struct {
+	// Strategy defines how the shortfall is divided between the child subnamespaces.
+	// +kubebuilder:default:=Proportional
+	// +optional
+	Strategy RebalanceStrategy `json:"strategy,omitempty"`
+	// Weights defines the weight of every child subnamespace when the Weighted strategy is used.
+	// Children which are not listed have a weight of 1, and children with a weight of 0 are not trimmed
+	// Possible values examples: {"team-a":3, "team-b":1}
+	// +optional
+	Weights map[string]int32 `json:"weights,omitempty"`
+}
+
+// RoundingMode defines how a multiplied quantity is rounded
+// +kubebuilder:validation:Enum=Floor;Ceil;Nearest
+type RoundingMode string
+
+const (
+	// RoundingModeFloor rounds down
+	RoundingModeFloor RoundingMode = "Floor"
+	// RoundingModeCeil rounds up
+	RoundingModeCeil RoundingMode = "Ceil"
+	// RoundingModeNearest rounds to the nearest value, and up if the value is halfway
+	RoundingModeNearest RoundingMode = "Nearest"
+)
+
+// NodeEligibility defines rules for excluding nodes from the capacity of a node group
+type NodeEligibility struct {
+	// ExcludeUnschedulable excludes cordoned nodes
+	// +optional
+	ExcludeUnschedulable bool `json:"excludeUnschedulable,omitempty"`
+	// ExcludeConditions excludes nodes which have any of the given conditions, e.g. Ready=False
+	// +optional
+	ExcludeConditions []NodeConditionRule `json:"excludeConditions,omitempty"`
+	// ExcludeTaints excludes nodes which carry any of the given taints
+	// +optional
+	ExcludeTaints []TaintRule `json:"excludeTaints,omitempty"`
+}
+
+// NodeConditionRule matches nodes which have a condition with the given status
+type NodeConditionRule struct {
+	// Type is the type of the node condition, e.g. Ready
+	Type corev1.NodeConditionType `json:"type"`
+	// Status is the status of the node condition
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	Status corev1.ConditionStatus `json:"status"`
+}
+
+// TaintRule matches nodes which carry a taint with the given key, and the given value and effect if they are set
+type TaintRule struct {
+	// Key is the key of the taint
+	Key string `json:"key"`
+	// Value is the value of the taint, any value matches if it is empty
+	// +optional
+	Value string `json:"value,omitempty"`
+	// Effect is the effect of the taint, any effect matches if it is empty
+	// +kubebuilder:validation:Enum=NoSchedule;PreferNoSchedule;NoExecute
+	// +optional
+	Effect corev1.TaintEffect `json:"effect,omitempty"`
+}
+
+// ExcludedNode shows a node which matches a node group but is excluded from its capacity
+type ExcludedNode struct {
+	// Name is the name of the node
+	Name string `json:"name"`
+	// Reason is the eligibility rule which excluded the node
+	Reason string `json:"reason"`
+	// Resources are the resources the node adds to the node group when it is not excluded
+	Resources corev1.ResourceList `json:"resources,omitempty"`
+}
+
+// QuotaStatus shows how the quota of a root or a secondary root was calculated
+type QuotaStatus struct {
+	// RootNamespace is the name of the root namespace
+	RootNamespace string `json:"rootNamespace"`
+	// SecondaryRoot is the name of the secondary root, it is empty for the entry of the root itself
+	SecondaryRoot string `json:"secondaryRoot,omitempty"`
+	// Nodes is the number of nodes matched by the node group
+	Nodes int `json:"nodes"`
+	// NodeNames are the names of the nodes matched by the node group, used to find out which nodes were removed
+	NodeNames []string `json:"nodeNames,omitempty"`
+	// ExcludedNodes are the nodes matched by the node group which are excluded by its eligibility rules,
+	// they are not part of Nodes and NodeNames
+	ExcludedNodes []ExcludedNode `json:"excludedNodes,omitempty"`
+	// Allocatable is the sum of the allocatable resources of the matched nodes
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+	// SystemResourceClaim is the sum of the resources claimed from the matched nodes for the system
+	SystemResourceClaim corev1.ResourceList `json:"systemResourceClaim,omitempty"`
+	// Multiplied is the sum of the resources of the matched nodes after applying the multipliers, the system claim is
+	// subtracted before the multipliers are applied when SubtractSystemResourceClaim is set
+	Multiplied corev1.ResourceList `json:"multiplied,omitempty"`
+	// ReservedResources is the debt of resources of removed nodes which is still kept in the quota
+	ReservedResources corev1.ResourceList `json:"reservedResources,omitempty"`
+	// Quota is the final quota calculated for the subnamespace
+	Quota corev1.ResourceList `json:"quota,omitempty"`
+	// ChildrenAllocated is the sum of the quotas allocated to the child subnamespaces of the secondary root
+	// +optional
+	ChildrenAllocated corev1.ResourceList `json:"childrenAllocated,omitempty"`
+	// Current is the quota which is currently set on the subnamespace, it is only shown in DryRun mode
+	// or when the writes of the quota are paused
+	// +optional
+	Current corev1.ResourceList `json:"current,omitempty"`
+	// PlannedChange is the difference between the calculated quota and the current quota of every resource which
+	// would be changed, it is only shown in DryRun mode or when the writes of the quota are paused
+	// +optional
+	PlannedChange corev1.ResourceList `json:"plannedChange,omitempty"`
+	// PlannedRebalances are the children of the secondary root whose quota would be trimmed by the rebalance policy of
+	// the node group, they are only shown in DryRun mode or when the writes of the quota are paused
+	// +optional
+	PlannedRebalances []PlannedRebalance `json:"plannedRebalances,omitempty"`
+	// LastSyncTime defines when the quota was last calculated
+	LastSyncTime metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// PlannedRebalance is a child of a secondary root whose quota would be trimmed by the rebalance policy of the node group
+type PlannedRebalance struct {
+	// Subnamespace is the name of the child subnamespace
+	Subnamespace string `json:"subnamespace"`
+	// Current is the quota which is currently set on the child subnamespace
+	Current corev1.ResourceList `json:"current,omitempty"`
+	// Quota is the quota the child subnamespace would be trimmed to
+	Quota corev1.ResourceList `json:"quota,omitempty"`
+	// PlannedChange is the difference between the trimmed quota and the current quota of every resource which would be changed
+	PlannedChange corev1.ResourceList `json:"plannedChange,omitempty"`
 }
 
 // NodeQuotaConfigStatus defines the observed state of NodeQuotaConfig
 type NodeQuotaConfigStatus struct {
+	// Conditions shows the state of the NodeQuotaConfig, such as Ready, Synced, Degraded and ReservationsActive
 	Conditions        []metav1.Condition  `json:"conditions,omitempty"`
 	ReservedResources []ReservedResources `json:"reservedResources,omitempty"`
+	// Quotas shows the calculated quota of every root and secondary root
+	Quotas []QuotaStatus `json:"quotas,omitempty"`
+	// ReservationRequests shows the latest manual requests to release or extend reservations
+	ReservationRequests []ReservationRequest `json:"reservationRequests,omitempty"`
+	// NodeSetHash is a hash of the nodes of the node groups when the quotas were last calculated, it is used for finding
+	// changes of the nodes when a settle window is set
+	NodeSetHash string `json:"nodeSetHash,omitempty"`
+	// CalculatedGeneration is the generation of the NodeQuotaConfig when the quotas were last calculated, a change of the
+	// spec is recalculated without waiting for the settle window
+	CalculatedGeneration int64 `json:"calculatedGeneration,omitempty"`
+	// PendingRecalculation shows that the nodes of the node groups changed since the quotas were last calculated,
+	// and when the quotas are recalculated if the nodes don't change again
+	PendingRecalculation *PendingRecalculation `json:"pendingRecalculation,omitempty"`
+	// OverlappingNodes shows the nodes which are selected by more than one node group
+	OverlappingNodes []NodeOverlap `json:"overlappingNodes,omitempty"`
+	// UnassignedNodes shows the nodes which are not selected by any node group, so their resources are not part of any quota
+	UnassignedNodes *UnassignedNodes `json:"unassignedNodes,omitempty"`
+	// Conflicts shows the roots and secondary roots of the NodeQuotaConfig which are managed by another NodeQuotaConfig
+	Conflicts []ConfigConflict `json:"conflicts,omitempty"`
+	// QuotaSnapshots shows the quotas of the roots and secondary roots from before the NodeQuotaConfig first wrote them,
+	// which are written back when the NodeQuotaConfig is deleted and its deletion policy is Restore
+	QuotaSnapshots []QuotaSnapshot `json:"quotaSnapshots,omitempty"`
+}
+
+// QuotaSnapshot is the quota of a root or a secondary root from before a NodeQuotaConfig first wrote it
+type QuotaSnapshot struct {
+	// RootNamespace is the name of the root
+	RootNamespace string `json:"rootNamespace"`
+	// SecondaryRoot is the name of the secondary root, it is empty for the snapshot of the root itself
+	SecondaryRoot string `json:"secondaryRoot,omitempty"`
+	// Hard is the quota of the controlled resources
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+	// SnapshotTime is when the snapshot was taken
+	SnapshotTime metav1.Time `json:"snapshotTime"`
+	// ManagedBefore is set when the quota was already written by the plugin when the snapshot was taken, e.g. for the
+	// NodeQuotaConfigs which existed before the snapshots were added, so the snapshot doesn't hold the quota from before
+	// the plugin managed it and isn't restored
+	// +optional
+	ManagedBefore bool `json:"managedBefore,omitempty"`
+}
+
+// ConfigConflict describes a root or a secondary root which is claimed by more than one NodeQuotaConfig.
+// Every root and secondary root is managed by the oldest NodeQuotaConfig which claims it
+type ConfigConflict struct {
+	// RootNamespace is the name of the claimed root, or the root of the claimed secondary root
+	RootNamespace string `json:"rootNamespace"`
+	// SecondaryRoot is the name of the claimed secondary root, it is empty when the root itself is claimed
+	SecondaryRoot string `json:"secondaryRoot,omitempty"`
+	// ManagedBy is the NodeQuotaConfig which manages the root or secondary root, as namespace/name
+	ManagedBy string `json:"managedBy"`
+}
+
+// PendingRecalculation describes a recalculation of the quotas which waits for the nodes to settle
+type PendingRecalculation struct {
+	// NodeSetHash is a hash of the nodes of the node groups since they last changed
+	NodeSetHash string `json:"nodeSetHash"`
+	// Since is the time the nodes of the node groups last changed
+	Since metav1.Time `json:"since"`
+	// RecalculateAfter is the time the quotas are recalculated if the nodes don't change again
+	RecalculateAfter metav1.Time `json:"recalculateAfter"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Mode",type="string",JSONPath=".spec.mode"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // NodeQuotaConfig is the Schema for the nodequotaconfigs API
 type NodeQuotaConfig struct {
@@ -21,12 +21,89 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigConflict) DeepCopyInto(out *ConfigConflict) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigConflict.
+func (in *ConfigConflict) DeepCopy() *ConfigConflict {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigConflict)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExcludedNode) DeepCopyInto(out *ExcludedNode) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExcludedNode.
+func (in *ExcludedNode) DeepCopy() *ExcludedNode {
+	if in == nil {
+		return nil
+	}
+	out := new(ExcludedNode)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeConditionRule) DeepCopyInto(out *NodeConditionRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeConditionRule.
+func (in *NodeConditionRule) DeepCopy() *NodeConditionRule {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeConditionRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeEligibility) DeepCopyInto(out *NodeEligibility) {
+	*out = *in
+	if in.ExcludeConditions != nil {
+		in, out := &in.ExcludeConditions, &out.ExcludeConditions
+		*out = make([]NodeConditionRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeTaints != nil {
+		in, out := &in.ExcludeTaints, &out.ExcludeTaints
+		*out = make([]TaintRule, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeEligibility.
+func (in *NodeEligibility) DeepCopy() *NodeEligibility {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeEligibility)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeGroup) DeepCopyInto(out *NodeGroup) {
 	*out = *in
@@ -37,6 +114,11 @@ func (in *NodeGroup) DeepCopyInto(out *NodeGroup) {
 			(*out)[key] = val
 		}
 	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ResourceMultiplier != nil {
 		in, out := &in.ResourceMultiplier, &out.ResourceMultiplier
 		*out = make(map[string]string, len(*in))
@@ -44,6 +126,13 @@ func (in *NodeGroup) DeepCopyInto(out *NodeGroup) {
 			(*out)[key] = val
 		}
 	}
+	if in.RoundingModes != nil {
+		in, out := &in.RoundingModes, &out.RoundingModes
+		*out = make(map[string]RoundingMode, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.SystemResourceClaim != nil {
 		in, out := &in.SystemResourceClaim, &out.SystemResourceClaim
 		*out = make(map[string]resource.Quantity, len(*in))
@@ -51,6 +140,16 @@ func (in *NodeGroup) DeepCopyInto(out *NodeGroup) {
 			(*out)[key] = val.DeepCopy()
 		}
 	}
+	if in.Eligibility != nil {
+		in, out := &in.Eligibility, &out.Eligibility
+		*out = new(NodeEligibility)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rebalance != nil {
+		in, out := &in.Rebalance, &out.Rebalance
+		*out = new(RebalancePolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeGroup.
@@ -63,6 +162,31 @@ func (in *NodeGroup) DeepCopy() *NodeGroup {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeOverlap) DeepCopyInto(out *NodeOverlap) {
+	*out = *in
+	if in.NodeGroups != nil {
+		in, out := &in.NodeGroups, &out.NodeGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OtherConfigs != nil {
+		in, out := &in.OtherConfigs, &out.OtherConfigs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeOverlap.
+func (in *NodeOverlap) DeepCopy() *NodeOverlap {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeOverlap)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeQuotaConfig) DeepCopyInto(out *NodeQuotaConfig) {
 	*out = *in
@@ -137,6 +261,11 @@ func (in *NodeQuotaConfigSpec) DeepCopyInto(out *NodeQuotaConfigSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SettleWindow != nil {
+		in, out := &in.SettleWindow, &out.SettleWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeQuotaConfigSpec.
@@ -154,7 +283,7 @@ func (in *NodeQuotaConfigStatus) DeepCopyInto(out *NodeQuotaConfigStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]metav1.Condition, len(*in))
+		*out = make([]v1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -166,6 +295,49 @@ func (in *NodeQuotaConfigStatus) DeepCopyInto(out *NodeQuotaConfigStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Quotas != nil {
+		in, out := &in.Quotas, &out.Quotas
+		*out = make([]QuotaStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReservationRequests != nil {
+		in, out := &in.ReservationRequests, &out.ReservationRequests
+		*out = make([]ReservationRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PendingRecalculation != nil {
+		in, out := &in.PendingRecalculation, &out.PendingRecalculation
+		*out = new(PendingRecalculation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverlappingNodes != nil {
+		in, out := &in.OverlappingNodes, &out.OverlappingNodes
+		*out = make([]NodeOverlap, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UnassignedNodes != nil {
+		in, out := &in.UnassignedNodes, &out.UnassignedNodes
+		*out = new(UnassignedNodes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]ConfigConflict, len(*in))
+		copy(*out, *in)
+	}
+	if in.QuotaSnapshots != nil {
+		in, out := &in.QuotaSnapshots, &out.QuotaSnapshots
+		*out = make([]QuotaSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeQuotaConfigStatus.
@@ -178,17 +350,231 @@ func (in *NodeQuotaConfigStatus) DeepCopy() *NodeQuotaConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingRecalculation) DeepCopyInto(out *PendingRecalculation) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	in.RecalculateAfter.DeepCopyInto(&out.RecalculateAfter)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingRecalculation.
+func (in *PendingRecalculation) DeepCopy() *PendingRecalculation {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingRecalculation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedRebalance) DeepCopyInto(out *PlannedRebalance) {
+	*out = *in
+	if in.Current != nil {
+		in, out := &in.Current, &out.Current
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.PlannedChange != nil {
+		in, out := &in.PlannedChange, &out.PlannedChange
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedRebalance.
+func (in *PlannedRebalance) DeepCopy() *PlannedRebalance {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedRebalance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaSnapshot) DeepCopyInto(out *QuotaSnapshot) {
+	*out = *in
+	if in.Hard != nil {
+		in, out := &in.Hard, &out.Hard
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	in.SnapshotTime.DeepCopyInto(&out.SnapshotTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaSnapshot.
+func (in *QuotaSnapshot) DeepCopy() *QuotaSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(QuotaSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaStatus) DeepCopyInto(out *QuotaStatus) {
+	*out = *in
+	if in.NodeNames != nil {
+		in, out := &in.NodeNames, &out.NodeNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludedNodes != nil {
+		in, out := &in.ExcludedNodes, &out.ExcludedNodes
+		*out = make([]ExcludedNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Allocatable != nil {
+		in, out := &in.Allocatable, &out.Allocatable
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.SystemResourceClaim != nil {
+		in, out := &in.SystemResourceClaim, &out.SystemResourceClaim
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Multiplied != nil {
+		in, out := &in.Multiplied, &out.Multiplied
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ReservedResources != nil {
+		in, out := &in.ReservedResources, &out.ReservedResources
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ChildrenAllocated != nil {
+		in, out := &in.ChildrenAllocated, &out.ChildrenAllocated
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Current != nil {
+		in, out := &in.Current, &out.Current
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.PlannedChange != nil {
+		in, out := &in.PlannedChange, &out.PlannedChange
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.PlannedRebalances != nil {
+		in, out := &in.PlannedRebalances, &out.PlannedRebalances
+		*out = make([]PlannedRebalance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.LastSyncTime.DeepCopyInto(&out.LastSyncTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaStatus.
+func (in *QuotaStatus) DeepCopy() *QuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(QuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RebalancePolicy) DeepCopyInto(out *RebalancePolicy) {
+	*out = *in
+	if in.Weights != nil {
+		in, out := &in.Weights, &out.Weights
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RebalancePolicy.
+func (in *RebalancePolicy) DeepCopy() *RebalancePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RebalancePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReservationRequest) DeepCopyInto(out *ReservationRequest) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	in.RequestTime.DeepCopyInto(&out.RequestTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReservationRequest.
+func (in *ReservationRequest) DeepCopy() *ReservationRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(ReservationRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReservedResources) DeepCopyInto(out *ReservedResources) {
 	*out = *in
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
-		*out = make(v1.ResourceList, len(*in))
+		*out = make(corev1.ResourceList, len(*in))
 		for key, val := range *in {
 			(*out)[key] = val.DeepCopy()
 		}
 	}
 	in.Timestamp.DeepCopyInto(&out.Timestamp)
+	if in.ExpirationTime != nil {
+		in, out := &in.ExpirationTime, &out.ExpirationTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReservedResources.
@@ -222,3 +608,45 @@ func (in *SubnamespacesRoots) DeepCopy() *SubnamespacesRoots {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaintRule) DeepCopyInto(out *TaintRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaintRule.
+func (in *TaintRule) DeepCopy() *TaintRule {
+	if in == nil {
+		return nil
+	}
+	out := new(TaintRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnassignedNodes) DeepCopyInto(out *UnassignedNodes) {
+	*out = *in
+	if in.NodeNames != nil {
+		in, out := &in.NodeNames, &out.NodeNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Allocatable != nil {
+		in, out := &in.Allocatable, &out.Allocatable
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnassignedNodes.
+func (in *UnassignedNodes) DeepCopy() *UnassignedNodes {
+	if in == nil {
+		return nil
+	}
+	out := new(UnassignedNodes)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -28,6 +28,7 @@ import (
 	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
 	"github.com/dana-team/hns-nqs-plugin/internal/controllers"
 	nqsmetrics "github.com/dana-team/hns-nqs-plugin/internal/metrics"
+	"github.com/dana-team/hns-nqs-plugin/internal/webhooks"
 	danav1 "github.com/dana-team/hns/api/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -38,6 +39,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -56,6 +58,7 @@ func init() {
 
 func main() {
 	var disableUpdates bool
+	var noWebhooks bool
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
@@ -74,6 +77,7 @@ func main() {
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.BoolVar(&disableUpdates, "disable-updates", false,
 		"Disable the updating of roots and secondary roots")
+	flag.BoolVar(&noWebhooks, "no-webhooks", false, "Disables webhooks")
 
 	opts := zap.Options{
 		Development: true,
@@ -151,6 +155,7 @@ func main() {
 	if err = (&controllers.NodeQuotaConfigReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("nodequotaconfig-controller"),
 		DisableUpdates: disableUpdates,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NodeQuotaConfig")
@@ -158,6 +163,17 @@ func main() {
 	}
 	// +kubebuilder:scaffold:builder
 
+	if !noWebhooks {
+		setupLog.Info("setting up webhooks")
+		webhookServer.Register("/validate-v1alpha1-nodequotaconfig", &webhook.Admission{Handler: &webhooks.NodeQuotaConfigValidator{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+		webhookServer.Register("/mutate-v1alpha1-nodequotaconfig", &webhook.Admission{Handler: &webhooks.NodeQuotaConfigMutator{
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+	}
+
 	nqsmetrics.InitializeNQSMetrics()
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
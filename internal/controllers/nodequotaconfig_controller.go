@@ -29,13 +29,19 @@ import (
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -48,6 +54,7 @@ import (
 type NodeQuotaConfigReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	DisableUpdates bool
 }
 
@@ -58,6 +65,7 @@ type NodeQuotaConfigReconciler struct {
 // +kubebuilder:rbac:groups="dana.hns.io",resources=subnamespaces,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=dana.hns.io,resources=nodequotaconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=dana.hns.io,resources=nodequotaconfigs/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *NodeQuotaConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
@@ -65,26 +73,99 @@ func (r *NodeQuotaConfigReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	config := &danav1alpha1.NodeQuotaConfig{}
 	if err := r.Get(ctx, types.NamespacedName{Name: req.Name, Namespace: req.Namespace}, config); err != nil {
 		if errors.IsNotFound(err) {
+			// the metrics are deleted by the finalizer, unless the NodeQuotaConfig was deleted without it
+			nqsmetrics.DeleteConfigMetrics(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
 	}
 
+	if !config.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, config, logger)
+	}
+	if !controllerutil.ContainsFinalizer(config, danav1alpha1.NodeQuotaConfigFinalizer) {
+		controllerutil.AddFinalizer(config, danav1alpha1.NodeQuotaConfigFinalizer)
+		if err := r.Update(ctx, config); err != nil {
+			logger.Error(err, "Error adding the finalizer to the NodeQuotaConfig")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// a NodeQuotaConfig which claims roots or secondary roots managed by an older NodeQuotaConfig doesn't write any quota
+	conflicts, err := utils.FindConfigConflicts(ctx, r.Client, *config)
+	if err != nil {
+		logger.Error(err, "Error finding conflicting NodeQuotaConfigs")
+		return ctrl.Result{}, err
+	}
+	config.Status.Conflicts = conflicts
+	utils.SetConflictedConditions(config)
+	if len(conflicts) > 0 {
+		logger.Info("NodeQuotaConfig is conflicted with another NodeQuotaConfig, skipping the calculation")
+		return ctrl.Result{}, r.UpdateConfigStatus(ctx, config, logger)
+	}
+
+	if err := r.handleReservationRequests(ctx, config, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// with a settle window, the quotas are recalculated only once the nodes of the node groups are stable for the window
+	var nodeSetHash string
+	if config.Spec.SettleWindow != nil {
+		hash, err := utils.NodeSetHash(ctx, r.Client, *config)
+		if err != nil {
+			logger.Error(err, "Error hashing the nodes of the node groups")
+			return ctrl.Result{}, err
+		}
+		nodeSetHash = hash
+		if untilRecalculation, pending := utils.SettleNodeSet(config, nodeSetHash); pending {
+			logger.Info(fmt.Sprintf("Nodes changed, recalculating in %s if they are stable", untilRecalculation.Round(time.Second)))
+			// only the recalculation from the nodes waits for the window, the quotas which were last calculated are still
+			// written, so reservation requests and expiries are applied and manual changes are reverted meanwhile
+			r.deleteExpiredReservations(config, logger)
+			r.WriteHeldQuotas(ctx, config, logger)
+			utils.SetReservationsCondition(config)
+			if err := r.UpdateConfigStatus(ctx, config, logger); err != nil {
+				return ctrl.Result{}, err
+			}
+			if untilExpiry, ok := utils.NextReservationExpiry(*config); ok && untilExpiry < untilRecalculation {
+				return ctrl.Result{RequeueAfter: untilExpiry}, nil
+			}
+			return ctrl.Result{RequeueAfter: untilRecalculation}, nil
+		}
+	}
+
 	logger.Info("Start calculating resources")
+	original := config.DeepCopy()
 	requeue, err := r.CalculateRootSubnamespaces(ctx, config, logger)
 	if err != nil {
+		// the calculation is incomplete, so only the conditions are updated on top of the previous status
+		utils.SetFailedConditions(original, utils.ReasonCalculationFailed, []string{err.Error()})
+		if statusErr := r.UpdateConfigStatus(ctx, original, logger); statusErr != nil {
+			return ctrl.Result{}, fmt.Errorf("%w, and failed to update the status: %w", err, statusErr)
+		}
 		return ctrl.Result{}, err
 	}
 
-	utils.DeleteExpiredReservedResources(config, logger)
+	r.deleteExpiredReservations(config, logger)
+	utils.SetReservationsCondition(config)
+	utils.SetNodeSetCalculated(config, nodeSetHash)
 	if err := r.UpdateConfigStatus(ctx, config, logger); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	updateNQSMetrics(config)
+	// the metrics of the roots aren't labeled by the NodeQuotaConfig, so a NodeQuotaConfig in DryRun mode next to the
+	// NodeQuotaConfig which manages the same roots doesn't publish them, and the metrics show the quotas which are written
+	configList := danav1alpha1.NodeQuotaConfigList{}
+	if err := r.List(ctx, &configList); err != nil {
+		return ctrl.Result{}, err
+	}
+	if !utils.ShadowsManagingConfig(*config, configList.Items) {
+		updateNQSMetrics(config, r.DisableUpdates)
+	}
 
-	if requeue {
-		return ctrl.Result{RequeueAfter: time.Duration(config.Spec.ReservedHoursToLive) * time.Hour}, nil
+	// every node's reservation expires on its own, so the config is requeued when the first one expires
+	if untilExpiry, ok := utils.NextReservationExpiry(*config); requeue && ok {
+		return ctrl.Result{RequeueAfter: untilExpiry}, nil
 	}
 
 	return ctrl.Result{}, nil
@@ -93,32 +174,79 @@ func (r *NodeQuotaConfigReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 // SetupWithManager sets up the controller with the Manager.
 func (r *NodeQuotaConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&danav1alpha1.NodeQuotaConfig{}).
+		// annotation changes are watched as well, since reservations are released and extended through annotations.
+		// setting the deletion timestamp changes the generation, so the deletion policy is applied on deletion
+		For(&danav1alpha1.NodeQuotaConfig{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		// the NodeQuotaConfigs which claim the same roots or secondary roots are reconciled when a NodeQuotaConfig
+		// changes or is deleted, so conflicts are found and resolved
+		Watches(
+			&danav1alpha1.NodeQuotaConfig{},
+			handler.EnqueueRequestsFromMapFunc(r.requestClaimingConfigReconcile),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		// only node changes which may change the capacity of node groups are watched, and only the configs
+		// which track the node before or after the change are reconciled
 		Watches(
 			&corev1.Node{},
-			handler.EnqueueRequestsFromMapFunc(r.requestConfigReconcile),
+			r.nodeEventHandler(),
+			builder.WithPredicates(nodeCapacityChangedPredicate()),
+		).
+		// the managed quotas are watched, so manual changes of them are reverted
+		Watches(
+			&danav1.Subnamespace{},
+			handler.EnqueueRequestsFromMapFunc(r.requestManagingConfigReconcile),
+			builder.WithPredicates(quotaHardChangedPredicate()),
+		).
+		Watches(
+			&corev1.ResourceQuota{},
+			handler.EnqueueRequestsFromMapFunc(r.requestManagingConfigReconcile),
+			builder.WithPredicates(quotaHardChangedPredicate()),
 		).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
 		Complete(r)
 }
 
 // CalculateRootSubnamespaces calculates the resource allocation for the root subnamespaces based on the provided NodeQuotaConfig.
 // It takes a context, the NodeQuotaConfig to reconcile, and a logger for logging informational messages.
 // It returns an error (if any occurred) during the calculation.
-// If an error occurs during the updating of the root subnamespaces, it logs the error but continues.
+// If an error occurs during the updating of the root subnamespaces, it logs the error but continues and reports it in the status conditions.
 // This is due to the fact that many times, errors occur because nodes have been added or removed from the cluster since the last calculation.
 func (r *NodeQuotaConfigReconciler) CalculateRootSubnamespaces(ctx context.Context, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) (bool, error) {
 	requeue := false
+	var failures []string
+	var shrinkProtected []string
+	// controlled resources of quotas which weren't written since they are owned by other field managers
+	var fieldConflicts []string
+	// in DryRun mode the quotas are only calculated and the planned changes are shown in the status
+	dryRun := config.Spec.Mode == danav1alpha1.ConfigModeDryRun
+	// the quotas which were written in the last reconciliation, for reverting manual changes of the quotas
+	lastSynced := utils.LastSyncedQuotas(*config)
+
+	overlaps, err := utils.FindNodeOverlaps(ctx, r.Client, *config)
+	if err != nil {
+		return false, fmt.Errorf("failed to find overlapping nodes: %w", err)
+	}
+	config.Status.OverlappingNodes = overlaps
+	utils.SetNodesOverlappingCondition(config)
+
+	unassigned, err := utils.FindUnassignedNodes(ctx, r.Client, *config)
+	if err != nil {
+		return false, fmt.Errorf("failed to find unassigned nodes: %w", err)
+	}
+	config.Status.UnassignedNodes = unassigned
+
+	utils.DeleteStaleQuotaStatuses(config)
+	utils.DeleteStaleQuotaSnapshots(config)
 	for _, rootSubnamespace := range config.Spec.Roots {
 		logger.Info(fmt.Sprintf("Starting to calculate RootSubnamespace %s", rootSubnamespace.RootNamespace))
+		calculationStart := time.Now()
 		rootResources := v1.ResourceList{}
 		var processedSecondaryRoots []danav1.Subnamespace
 
 		for _, secondaryRoot := range rootSubnamespace.SecondaryRoots {
 			logger.Info(fmt.Sprintf("Starting to calculate Secondary root %s", secondaryRoot.Name))
-			secondaryRootSns, secondaryRequeue, err := utils.ProcessSecondaryRoot(ctx, r.Client, secondaryRoot, config, rootSubnamespace.RootNamespace, logger)
+			secondaryRootSns, secondaryRequeue, err := utils.ProcessSecondaryRoot(ctx, r.Client, r.Recorder, secondaryRoot, config, rootSubnamespace.RootNamespace, logger)
 			if err != nil {
-				return false, err
+				return false, fmt.Errorf("failed to calculate secondary root %s of root %s: %w", secondaryRoot.Name, rootSubnamespace.RootNamespace, err)
 			}
 
 			// it's enough that one secondaryRoot signals a requeue
@@ -126,23 +254,180 @@ func (r *NodeQuotaConfigReconciler) CalculateRootSubnamespaces(ctx context.Conte
 				requeue = true
 			}
 
+			rebalanced, err := utils.RebalanceSecondaryRootChildren(ctx, r.Client, &secondaryRootSns, secondaryRoot, config, logger)
+			if err != nil {
+				return false, fmt.Errorf("failed to rebalance the children of secondary root %s of root %s: %w", secondaryRoot.Name, rootSubnamespace.RootNamespace, err)
+			}
+
+			protected, err := utils.ProtectSecondaryRootShrink(ctx, r.Client, r.Recorder, &secondaryRootSns, rebalanced, config, logger)
+			if err != nil {
+				return false, fmt.Errorf("failed to check the shrink of secondary root %s of root %s: %w", secondaryRoot.Name, rootSubnamespace.RootNamespace, err)
+			}
+			shrinkProtected = append(shrinkProtected, protected...)
+
+			// the children are trimmed before their secondary root shrinks below the sum of their quotas
+			processedSecondaryRoots = append(processedSecondaryRoots, rebalanced...)
 			processedSecondaryRoots = append(processedSecondaryRoots, secondaryRootSns)
 			rootResources = utils.MergeTwoResourceList(secondaryRootSns.Spec.ResourceQuotaSpec.Hard, rootResources)
 		}
-		if !r.DisableUpdates {
-			if err := utils.UpdateRootSubnamespace(ctx, rootResources, rootSubnamespace, logger, r.Client); err != nil {
-				logger.Info(fmt.Sprintf("Error updating root subnamespace %s: %v", rootSubnamespace.RootNamespace, err.Error()))
-			}
-
-			if err := utils.UpdateProcessedSecondaryRoots(ctx, processedSecondaryRoots, logger, r.Client); err != nil {
-				logger.Info(fmt.Sprintf("Error updating secondary root subnamespace: %v", err.Error()))
+		utils.SetRootQuotaStatusToConfig(rootSubnamespace, rootResources, config)
+		nqsmetrics.ObserveCalculationDuration(rootSubnamespace.RootNamespace, time.Since(calculationStart).Seconds())
+
+		// paused quotas aren't written but are still calculated, so their planned changes are shown as in DryRun mode
+		if dryRun || utils.IsRootPaused(*config, rootSubnamespace) {
+			if err := utils.SetPlannedChangesToConfig(ctx, r.Client, rootSubnamespace, processedSecondaryRoots, config, logger); err != nil {
+				logger.Info(fmt.Sprintf("Error planning root subnamespace %s: %v", rootSubnamespace.RootNamespace, err.Error()))
+				failures = append(failures, fmt.Sprintf("failed to plan root %s: %v", rootSubnamespace.RootNamespace, err))
+				continue
 			}
+		}
 
+		if !r.DisableUpdates && !dryRun {
+			conflicts, rootFailures := r.writeRootQuotas(ctx, config, rootSubnamespace, rootResources, processedSecondaryRoots, lastSynced, logger)
+			fieldConflicts = append(fieldConflicts, conflicts...)
+			failures = append(failures, rootFailures...)
 		}
 	}
+
+	utils.SetShrinkBlockedCondition(config, shrinkProtected)
+	r.setWriteConditions(config, failures, fieldConflicts)
 	return requeue, nil
 }
 
+// WriteHeldQuotas writes the quotas which were last calculated while the recalculation from the nodes is pending, with
+// the active reservations of every node group. The rebalanced children of the secondary roots are written once the quotas
+// are recalculated.
+func (r *NodeQuotaConfigReconciler) WriteHeldQuotas(ctx context.Context, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) {
+	var failures []string
+	var fieldConflicts []string
+	dryRun := config.Spec.Mode == danav1alpha1.ConfigModeDryRun
+	lastSynced := utils.LastSyncedQuotas(*config)
+
+	for _, rootSubnamespace := range config.Spec.Roots {
+		rootResources, secondaryRoots, ok := utils.HeldQuotas(config, rootSubnamespace)
+		if !ok {
+			logger.Info(fmt.Sprintf("Skipping root subnamespace %s, it wasn't calculated yet", rootSubnamespace.RootNamespace))
+			continue
+		}
+		if !r.DisableUpdates && !dryRun {
+			conflicts, rootFailures := r.writeRootQuotas(ctx, config, rootSubnamespace, rootResources, secondaryRoots, lastSynced, logger)
+			fieldConflicts = append(fieldConflicts, conflicts...)
+			failures = append(failures, rootFailures...)
+		}
+	}
+	r.setWriteConditions(config, failures, fieldConflicts)
+}
+
+// writeRootQuotas takes the snapshots of the quotas of the root and its secondary roots, and writes the quotas.
+// It returns the controlled resources which weren't written since they are owned by other field managers, and the failures.
+func (r *NodeQuotaConfigReconciler) writeRootQuotas(ctx context.Context, config *danav1alpha1.NodeQuotaConfig, rootSubnamespace danav1alpha1.SubnamespacesRoots, rootResources v1.ResourceList, processedSecondaryRoots []danav1.Subnamespace, lastSynced []danav1alpha1.QuotaStatus, logger logr.Logger) ([]string, []string) {
+	var failures []string
+	var fieldConflicts []string
+
+	// the quotas are snapshotted before they are first written, so they can be restored when the config is deleted
+	if err := utils.SnapshotQuotas(ctx, r.Client, rootSubnamespace, config); err != nil {
+		logger.Info(fmt.Sprintf("Error taking a snapshot of root subnamespace %s: %v", rootSubnamespace.RootNamespace, err.Error()))
+		return nil, []string{fmt.Sprintf("failed to take a snapshot of root %s: %v", rootSubnamespace.RootNamespace, err)}
+	}
+
+	err := utils.UpdateRootSubnamespace(ctx, rootResources, rootSubnamespace, logger, r.Client, r.Recorder, config, lastSynced)
+	if conflicts := utils.FieldConflicts(err); conflicts != nil {
+		fieldConflicts = append(fieldConflicts, conflicts...)
+	} else if err != nil {
+		logger.Info(fmt.Sprintf("Error updating root subnamespace %s: %v", rootSubnamespace.RootNamespace, err.Error()))
+		failures = append(failures, fmt.Sprintf("failed to update root %s: %v", rootSubnamespace.RootNamespace, err))
+	}
+
+	err = utils.UpdateProcessedSecondaryRoots(ctx, processedSecondaryRoots, logger, r.Client, r.Recorder, config, lastSynced)
+	if conflicts := utils.FieldConflicts(err); conflicts != nil {
+		fieldConflicts = append(fieldConflicts, conflicts...)
+	} else if err != nil {
+		logger.Info(fmt.Sprintf("Error updating secondary root subnamespace: %v", err.Error()))
+		failures = append(failures, fmt.Sprintf("root %s: %v", rootSubnamespace.RootNamespace, err))
+	}
+	return fieldConflicts, failures
+}
+
+// setWriteConditions sets the conditions of the NodeQuotaConfig according to the writes of its quotas.
+func (r *NodeQuotaConfigReconciler) setWriteConditions(config *danav1alpha1.NodeQuotaConfig, failures, fieldConflicts []string) {
+	utils.SetFieldsConflictedCondition(config, fieldConflicts)
+	if len(failures) > 0 {
+		utils.SetFailedConditions(config, utils.ReasonUpdateFailed, failures)
+	} else if len(fieldConflicts) > 0 {
+		utils.SetFailedConditions(config, utils.ReasonFieldConflict, fieldConflicts)
+	} else {
+		utils.SetSyncedConditions(config, r.DisableUpdates)
+	}
+}
+
+// deleteExpiredReservations deletes the expired reservations from the status of the NodeQuotaConfig and records their expiry.
+func (r *NodeQuotaConfigReconciler) deleteExpiredReservations(config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) {
+	for _, expired := range utils.DeleteExpiredReservedResources(config, logger) {
+		message := fmt.Sprintf("reservation of %s for node group %s expired", utils.DescribeReservation(expired), expired.NodeGroup)
+		if expired.ExpirationTime == nil {
+			message = fmt.Sprintf("%s after %d hours", message, config.Spec.ReservedHoursToLive)
+		}
+		utils.RecordReservationEvent(r.Recorder, config, corev1.EventTypeWarning, utils.EventReasonReservationExpired, message, expired.NodeGroup)
+	}
+}
+
+// finalize applies the deletion policy of the NodeQuotaConfig to its quotas, deletes its metrics and removes its finalizer.
+// The quotas are not touched when the NodeQuotaConfig never wrote them, since it is in DryRun mode or updates are disabled.
+func (r *NodeQuotaConfigReconciler) finalize(ctx context.Context, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) error {
+	if !controllerutil.ContainsFinalizer(config, danav1alpha1.NodeQuotaConfigFinalizer) {
+		return nil
+	}
+
+	if !r.DisableUpdates && config.Spec.Mode != danav1alpha1.ConfigModeDryRun {
+		if config.Spec.DeletionPolicy == danav1alpha1.DeletionPolicyRestore {
+			logger.Info("NodeQuotaConfig is being deleted, restoring the quotas")
+			if err := utils.RestoreQuotaSnapshots(ctx, r.Client, r.Recorder, config, logger); err != nil {
+				return err
+			}
+		} else {
+			logger.Info("NodeQuotaConfig is being deleted, leaving the quotas as they are")
+			if err := utils.OrphanQuotas(ctx, r.Client, r.Recorder, config, logger); err != nil {
+				logger.Error(err, "Error orphaning the quotas")
+				return err
+			}
+		}
+	}
+	nqsmetrics.DeleteConfigMetrics(config.Namespace, config.Name)
+
+	controllerutil.RemoveFinalizer(config, danav1alpha1.NodeQuotaConfigFinalizer)
+	if err := r.Update(ctx, config); err != nil {
+		logger.Error(err, "Error removing the finalizer from the NodeQuotaConfig")
+		return err
+	}
+	return nil
+}
+
+// handleReservationRequests releases or extends reservations according to the annotations of the NodeQuotaConfig.
+// The annotations are removed from the NodeQuotaConfig before the requests are applied, so every request is applied once.
+func (r *NodeQuotaConfigReconciler) handleReservationRequests(ctx context.Context, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) error {
+	if !utils.HasReservationRequests(config.Annotations) {
+		return nil
+	}
+
+	requests, parseErr := utils.ParseReservationRequests(config.Annotations)
+	patch := client.MergeFrom(config.DeepCopy())
+	delete(config.Annotations, danav1alpha1.ReleaseReservationsAnnotation)
+	delete(config.Annotations, danav1alpha1.ExtendReservationsAnnotation)
+	delete(config.Annotations, danav1alpha1.ReservationRequesterAnnotation)
+	if err := r.Patch(ctx, config, patch); err != nil {
+		logger.Error(err, "Error removing the reservation requests annotations")
+		return err
+	}
+
+	if parseErr != nil {
+		logger.Info(fmt.Sprintf("Ignoring reservation requests: %v", parseErr.Error()))
+		utils.RecordEvent(r.Recorder, corev1.EventTypeWarning, utils.EventReasonInvalidReservationRequest, parseErr.Error(), config)
+		return nil
+	}
+	utils.ApplyReservationRequests(config, requests, r.Recorder, logger)
+	return nil
+}
+
 // UpdateConfigStatus updates the status of the NodeQuotaConfig if it's different from the current status.
 func (r *NodeQuotaConfigReconciler) UpdateConfigStatus(ctx context.Context, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) error {
 	if err := r.Status().Update(ctx, config); err != nil {
@@ -152,35 +437,189 @@ func (r *NodeQuotaConfigReconciler) UpdateConfigStatus(ctx context.Context, conf
 	return nil
 }
 
-// requestConfigReconcile generates a list of reconcile requests for NodeQuotaConfig objects that need to be reconciled.
-// It takes a context and the node object.
-// It returns a slice of reconcile requests ([]reconcile.Request).
-func (r *NodeQuotaConfigReconciler) requestConfigReconcile(ctx context.Context, node client.Object) []reconcile.Request {
+// nodeEventHandler enqueues the NodeQuotaConfig objects which track a node which was created or deleted,
+// or which track an updated node according to either its old or its new labels.
+func (r *NodeQuotaConfigReconciler) nodeEventHandler() handler.Funcs {
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueSelectingConfigs(ctx, queue, e.Object.GetLabels())
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueSelectingConfigs(ctx, queue, e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueSelectingConfigs(ctx, queue, e.Object.GetLabels())
+		},
+	}
+}
+
+// enqueueSelectingConfigs enqueues the NodeQuotaConfig objects which track a node with one of the given label sets: the
+// NodeQuotaConfigs whose node groups select the node, or whose unassigned nodes or catch-all node group it may be part of.
+func (r *NodeQuotaConfigReconciler) enqueueSelectingConfigs(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request], nodeLabels ...map[string]string) {
 	nodeQuotaConfig := danav1alpha1.NodeQuotaConfigList{}
-	err := r.List(ctx, &nodeQuotaConfig)
-	if err != nil {
+	if err := r.List(ctx, &nodeQuotaConfig); err != nil {
+		log.FromContext(ctx).Error(err, "Error listing the NodeQuotaConfigs of a node event")
+		return
+	}
+
+	for _, item := range nodeQuotaConfig.Items {
+		for _, labels := range nodeLabels {
+			if utils.ConfigTracksNode(item, nodeQuotaConfig.Items, labels) {
+				queue.Add(reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      item.GetName(),
+						Namespace: item.GetNamespace(),
+					},
+				})
+				break
+			}
+		}
+	}
+}
+
+// requestClaimingConfigReconcile generates reconcile requests for the other NodeQuotaConfig objects which claim a root
+// or a secondary root of the given NodeQuotaConfig, or which are conflicted with it.
+func (r *NodeQuotaConfigReconciler) requestClaimingConfigReconcile(ctx context.Context, object client.Object) []reconcile.Request {
+	changed, ok := object.(*danav1alpha1.NodeQuotaConfig)
+	if !ok {
+		return []reconcile.Request{}
+	}
+	nodeQuotaConfig := danav1alpha1.NodeQuotaConfigList{}
+	if err := r.List(ctx, &nodeQuotaConfig); err != nil {
 		return []reconcile.Request{}
 	}
 
-	requests := make([]reconcile.Request, len(nodeQuotaConfig.Items))
-	for i, item := range nodeQuotaConfig.Items {
-		requests[i] = reconcile.Request{
-			NamespacedName: types.NamespacedName{
-				Name:      item.GetName(),
-				Namespace: item.GetNamespace(),
-			},
+	changedName := fmt.Sprintf("%s/%s", changed.Namespace, changed.Name)
+	var requests []reconcile.Request
+	for _, item := range nodeQuotaConfig.Items {
+		if item.Namespace == changed.Namespace && item.Name == changed.Name {
+			continue
+		}
+		if utils.ClaimsOverlap(item, *changed) || isConflictedWith(item, changedName) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.GetName(),
+					Namespace: item.GetNamespace(),
+				},
+			})
 		}
 	}
 	return requests
 }
 
+// isConflictedWith checks if the NodeQuotaConfig is conflicted with the NodeQuotaConfig of the given namespace/name.
+func isConflictedWith(config danav1alpha1.NodeQuotaConfig, managedBy string) bool {
+	for _, conflict := range config.Status.Conflicts {
+		if conflict.ManagedBy == managedBy {
+			return true
+		}
+	}
+	return false
+}
+
+// requestManagingConfigReconcile generates reconcile requests for the NodeQuotaConfig objects which manage the quota of
+// the given root ResourceQuota or secondary root Subnamespace.
+func (r *NodeQuotaConfigReconciler) requestManagingConfigReconcile(ctx context.Context, object client.Object) []reconcile.Request {
+	nodeQuotaConfig := danav1alpha1.NodeQuotaConfigList{}
+	if err := r.List(ctx, &nodeQuotaConfig); err != nil {
+		return []reconcile.Request{}
+	}
+
+	var requests []reconcile.Request
+	for _, item := range nodeQuotaConfig.Items {
+		if managesQuota(item, object) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.GetName(),
+					Namespace: item.GetNamespace(),
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// managesQuota checks if the object is the ResourceQuota of a root or the Subnamespace of a secondary root of the NodeQuotaConfig.
+func managesQuota(config danav1alpha1.NodeQuotaConfig, object client.Object) bool {
+	for _, root := range config.Spec.Roots {
+		if object.GetNamespace() != root.RootNamespace {
+			continue
+		}
+		switch object.(type) {
+		case *corev1.ResourceQuota:
+			if object.GetName() == root.RootNamespace {
+				return true
+			}
+		case *danav1.Subnamespace:
+			for _, secondaryRoot := range root.SecondaryRoots {
+				if object.GetName() == secondaryRoot.Name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// quotaHardChangedPredicate passes updates of ResourceQuotas and Subnamespaces which change their hard quota.
+func quotaHardChangedPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !equality.Semantic.DeepEqual(quotaHard(e.ObjectOld), quotaHard(e.ObjectNew))
+		},
+	}
+}
+
+// quotaHard returns the hard quota of a ResourceQuota or a Subnamespace.
+func quotaHard(object client.Object) v1.ResourceList {
+	switch quota := object.(type) {
+	case *corev1.ResourceQuota:
+		return quota.Spec.Hard
+	case *danav1.Subnamespace:
+		return quota.Spec.ResourceQuotaSpec.Hard
+	}
+	return nil
+}
+
+// nodeCapacityChangedPredicate passes node events which may change the capacity of node groups: creations, deletions,
+// and updates of the allocatable resources, labels, unschedulable flag, taints or the status of the conditions of the node.
+// Heartbeat-driven status updates don't change any of these and are filtered out.
+func nodeCapacityChangedPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldNode, ok := e.ObjectOld.(*corev1.Node)
+			if !ok {
+				return false
+			}
+			newNode, ok := e.ObjectNew.(*corev1.Node)
+			if !ok {
+				return false
+			}
+			return !equality.Semantic.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable) ||
+				!equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) ||
+				oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable ||
+				!equality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints) ||
+				!equality.Semantic.DeepEqual(nodeConditionStatuses(oldNode), nodeConditionStatuses(newNode))
+		},
+	}
+}
+
+// nodeConditionStatuses returns the status of every condition of the node, without the heartbeat times.
+func nodeConditionStatuses(node *corev1.Node) map[corev1.NodeConditionType]corev1.ConditionStatus {
+	statuses := map[corev1.NodeConditionType]corev1.ConditionStatus{}
+	for _, condition := range node.Status.Conditions {
+		statuses[condition.Type] = condition.Status
+	}
+	return statuses
+}
+
 // updateOvercommitMultiplierMetrics updates the metrics for overcommit multiplier for each secondary root in the NodeQuotaConfig.
-func updateOvercommitMultiplierMetrics(config *danav1alpha1.NodeQuotaConfig) {
+func updateOvercommitMultiplierMetrics(metrics *nqsmetrics.ConfigMetrics, config *danav1alpha1.NodeQuotaConfig) {
 	for _, root := range config.Spec.Roots {
 		for _, secondaryRoot := range root.SecondaryRoots {
 			for resource, value := range secondaryRoot.ResourceMultiplier {
 				if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
-					nqsmetrics.ObserveOverCommitMultiplier(resource, root.RootNamespace, secondaryRoot.Name, floatValue)
+					metrics.ObserveOverCommitMultiplier(resource, root.RootNamespace, secondaryRoot.Name, floatValue)
 				}
 			}
 		}
@@ -188,19 +627,73 @@ func updateOvercommitMultiplierMetrics(config *danav1alpha1.NodeQuotaConfig) {
 }
 
 // updateSystemClaimMetrics updates the metrics for system resource claims for each secondary root
-func updateSystemClaimMetrics(config *danav1alpha1.NodeQuotaConfig) {
+func updateSystemClaimMetrics(metrics *nqsmetrics.ConfigMetrics, config *danav1alpha1.NodeQuotaConfig) {
 	for _, root := range config.Spec.Roots {
 		for _, secondaryRoot := range root.SecondaryRoots {
 			for resourceName, quantity := range secondaryRoot.SystemResourceClaim {
 				value := float64(quantity.Value())
-				nqsmetrics.ObserveSystemClaimResources(resourceName, root.RootNamespace, secondaryRoot.Name, value)
+				metrics.ObserveSystemClaimResources(resourceName, root.RootNamespace, secondaryRoot.Name, value)
 			}
 		}
 	}
 }
 
 // updateNQSMetrics updates the metrics for the overcommit multiplier for each secondary root in the NodeQuotaConfig.
-func updateNQSMetrics(config *danav1alpha1.NodeQuotaConfig) {
-	updateOvercommitMultiplierMetrics(config)
-	updateSystemClaimMetrics(config)
+// The series of the NodeQuotaConfig which are not set in this update, e.g. of a removed secondary root, are deleted.
+func updateNQSMetrics(config *danav1alpha1.NodeQuotaConfig, updatesDisabled bool) {
+	metrics := nqsmetrics.NewConfigMetrics(config.Namespace, config.Name)
+	updateOvercommitMultiplierMetrics(metrics, config)
+	updateSystemClaimMetrics(metrics, config)
+	updateQuotaMetrics(metrics, config, updatesDisabled)
+	metrics.ObserveOverlappingNodes(float64(len(config.Status.OverlappingNodes)))
+	updateUnassignedNodesMetrics(metrics, config)
+	metrics.Commit()
+}
+
+// updateQuotaMetrics updates the metrics of the capacity, quota and reservations of each secondary root in the NodeQuotaConfig,
+// so the real capacity of the node groups can be compared with the quota which is sold.
+// The quota metric is the quota which is set on the secondary root: the calculated quota once it is written, or the current
+// quota when the writes are paused or in DryRun mode. It is not updated when updates are disabled.
+func updateQuotaMetrics(metrics *nqsmetrics.ConfigMetrics, config *danav1alpha1.NodeQuotaConfig, updatesDisabled bool) {
+	now := time.Now()
+	for _, quotaStatus := range config.Status.Quotas {
+		if quotaStatus.SecondaryRoot == "" {
+			continue
+		}
+		rootNS, secondaryRoot := quotaStatus.RootNamespace, quotaStatus.SecondaryRoot
+		metrics.ObserveNodeGroupNodes(rootNS, secondaryRoot, float64(quotaStatus.Nodes))
+		for _, resourceName := range config.Spec.ControlledResources {
+			name := v1.ResourceName(resourceName)
+			allocatable, capacity, reserved := quotaStatus.Allocatable[name], quotaStatus.Multiplied[name], quotaStatus.ReservedResources[name]
+			metrics.ObserveNodeGroupAllocatable(resourceName, rootNS, secondaryRoot, allocatable.AsApproximateFloat64())
+			metrics.ObserveNodeGroupCapacity(resourceName, rootNS, secondaryRoot, capacity.AsApproximateFloat64())
+			metrics.ObserveReservedResources(resourceName, rootNS, secondaryRoot, reserved.AsApproximateFloat64())
+
+			if quotaStatus.Current != nil {
+				current := quotaStatus.Current[name]
+				metrics.ObserveQuotaResources(resourceName, rootNS, secondaryRoot, current.AsApproximateFloat64())
+			} else if !updatesDisabled {
+				quota := quotaStatus.Quota[name]
+				metrics.ObserveQuotaResources(resourceName, rootNS, secondaryRoot, quota.AsApproximateFloat64())
+			}
+		}
+
+		age := 0.0
+		if oldest, ok := utils.OldestReservation(*config, secondaryRoot); ok {
+			age = now.Sub(oldest.Time).Seconds()
+		}
+		metrics.ObserveReservationAge(rootNS, secondaryRoot, age)
+	}
+}
+
+// updateUnassignedNodesMetrics updates the metrics of the nodes which are not selected by any node group of the NodeQuotaConfig.
+func updateUnassignedNodesMetrics(metrics *nqsmetrics.ConfigMetrics, config *danav1alpha1.NodeQuotaConfig) {
+	if config.Status.UnassignedNodes == nil {
+		return
+	}
+	metrics.ObserveUnassignedNodes(float64(config.Status.UnassignedNodes.Nodes))
+	for _, resourceName := range config.Spec.ControlledResources {
+		quantity := config.Status.UnassignedNodes.Allocatable[v1.ResourceName(resourceName)]
+		metrics.ObserveUnassignedAllocatable(resourceName, quantity.AsApproximateFloat64())
+	}
 }
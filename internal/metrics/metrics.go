@@ -1,6 +1,9 @@
 package metrics
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -17,28 +20,351 @@ var systemClaimResources = prometheus.NewGaugeVec(
 		Help: "Amount of resources reserved per node",
 	}, []string{"resource", "root_namespace", "secondary_root_namespace"})
 
+var nodeGroupAllocatable = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_node_group_allocatable_resources",
+		Help: "Allocatable resources of the nodes of a secondary root before the system claim and the multipliers",
+	}, []string{"resource", "root_namespace", "secondary_root_namespace"})
+
+var nodeGroupCapacity = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_node_group_capacity_resources",
+		Help: "Resources of the nodes of a secondary root after the system claim and the multipliers",
+	}, []string{"resource", "root_namespace", "secondary_root_namespace"})
+
+var quotaResources = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_quota_resources",
+		Help: "Quota set on a secondary root",
+	}, []string{"resource", "root_namespace", "secondary_root_namespace"})
+
+var reservedResources = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_reserved_resources",
+		Help: "Resources of removed nodes which are still kept in the quota of a secondary root",
+	}, []string{"resource", "root_namespace", "secondary_root_namespace"})
+
+var reservationAge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_reservation_age_seconds",
+		Help: "Age of the oldest active reservation of a secondary root in seconds",
+	}, []string{"root_namespace", "secondary_root_namespace"})
+
+var nodeGroupNodes = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_node_group_nodes",
+		Help: "Number of nodes matched by the node group of a secondary root",
+	}, []string{"root_namespace", "secondary_root_namespace"})
+
+var quotaWrites = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nqs_quota_writes_total",
+		Help: "Number of attempted writes of the quota of a root or a secondary root by their result",
+	}, []string{"root_namespace", "secondary_root_namespace", "result"})
+
+var quotaWriteConflicts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nqs_quota_write_conflicts_total",
+		Help: "Number of writes of the quota of a root or a secondary root which conflicted with another change of the quota",
+	}, []string{"root_namespace", "secondary_root_namespace"})
+
+var reservationEvents = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nqs_reservation_events_total",
+		Help: "Number of reservations of a secondary root which were started, updated, released or expired",
+	}, []string{"root_namespace", "secondary_root_namespace", "event"})
+
+var calculationDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "nqs_calculation_duration_seconds",
+		Help:    "Duration of the calculation of the quotas of a root and its secondary roots",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"root_namespace"})
+
+var overlappingNodes = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_overlapping_nodes",
+		Help: "Number of nodes selected by more than one node group",
+	}, []string{"namespace", "nodequotaconfig"})
+
+var unassignedNodes = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_unassigned_nodes",
+		Help: "Number of nodes not selected by any node group",
+	}, []string{"namespace", "nodequotaconfig"})
+
+var unassignedAllocatable = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nqs_unassigned_allocatable_resources",
+		Help: "Allocatable resources of the nodes not selected by any node group",
+	}, []string{"namespace", "nodequotaconfig", "resource"})
+
 // InitializeNQSMetrics initializes the metrics for NQS.
 func InitializeNQSMetrics() {
 	metrics.Registry.MustRegister(
 		resourceOverCommitMultiplier,
 		systemClaimResources,
+		nodeGroupAllocatable,
+		nodeGroupCapacity,
+		quotaResources,
+		reservedResources,
+		reservationAge,
+		nodeGroupNodes,
+		quotaWrites,
+		quotaWriteConflicts,
+		reservationEvents,
+		calculationDuration,
+		overlappingNodes,
+		unassignedNodes,
+		unassignedAllocatable,
 	)
 }
 
+// ConfigMetrics sets the gauges of a NodeQuotaConfig. It tracks the series it sets, so when it is committed, the series which
+// were set in the previous update of the NodeQuotaConfig and were not set again are deleted, e.g. when a secondary root
+// is renamed or removed from the spec.
+type ConfigMetrics struct {
+	namespace string
+	name      string
+	observed  map[string]series
+}
+
+// series is a series of a gauge along with its labels.
+type series struct {
+	gauge  *prometheus.GaugeVec
+	labels prometheus.Labels
+}
+
+var (
+	// ownedSeriesLock guards ownedSeries, since NodeQuotaConfigs may be reconciled concurrently.
+	ownedSeriesLock sync.Mutex
+	// ownedSeries holds the series which were set in the last update of every NodeQuotaConfig, by namespace/name.
+	ownedSeries = map[string]map[string]series{}
+)
+
+// NewConfigMetrics returns a ConfigMetrics which sets the gauges of the given NodeQuotaConfig.
+func NewConfigMetrics(namespace, config string) *ConfigMetrics {
+	return &ConfigMetrics{namespace: namespace, name: config, observed: map[string]series{}}
+}
+
+// set sets a series of a gauge and tracks it as owned by the NodeQuotaConfig.
+func (m *ConfigMetrics) set(gauge *prometheus.GaugeVec, labels prometheus.Labels, value float64) {
+	gauge.With(labels).Set(value)
+	m.observed[fmt.Sprintf("%p%v", gauge, labels)] = series{gauge: gauge, labels: labels}
+}
+
 // ObserveOverCommitMultiplier sets the overcommit multiplier for a given resource.
-func ObserveOverCommitMultiplier(resource, rootNS, secondaryRoot string, value float64) {
-	resourceOverCommitMultiplier.With(prometheus.Labels{
+func (m *ConfigMetrics) ObserveOverCommitMultiplier(resource, rootNS, secondaryRoot string, value float64) {
+	m.set(resourceOverCommitMultiplier, prometheus.Labels{
 		"resource":                 resource,
 		"root_namespace":           rootNS,
 		"secondary_root_namespace": secondaryRoot,
-	}).Set(value)
+	}, value)
 }
 
 // ObserveSystemClaimResources sets the amount of reserved resources for a given resource type.
-func ObserveSystemClaimResources(resource, rootNS, secondaryRoot string, value float64) {
-	systemClaimResources.With(prometheus.Labels{
+func (m *ConfigMetrics) ObserveSystemClaimResources(resource, rootNS, secondaryRoot string, value float64) {
+	m.set(systemClaimResources, prometheus.Labels{
+		"resource":                 resource,
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+	}, value)
+}
+
+// ObserveNodeGroupAllocatable sets the allocatable amount of a resource of the nodes of a secondary root.
+func (m *ConfigMetrics) ObserveNodeGroupAllocatable(resource, rootNS, secondaryRoot string, value float64) {
+	m.set(nodeGroupAllocatable, prometheus.Labels{
+		"resource":                 resource,
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+	}, value)
+}
+
+// ObserveNodeGroupCapacity sets the amount of a resource of the nodes of a secondary root after the multipliers.
+func (m *ConfigMetrics) ObserveNodeGroupCapacity(resource, rootNS, secondaryRoot string, value float64) {
+	m.set(nodeGroupCapacity, prometheus.Labels{
+		"resource":                 resource,
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+	}, value)
+}
+
+// ObserveQuotaResources sets the quota of a resource which is set on a secondary root.
+func (m *ConfigMetrics) ObserveQuotaResources(resource, rootNS, secondaryRoot string, value float64) {
+	m.set(quotaResources, prometheus.Labels{
+		"resource":                 resource,
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+	}, value)
+}
+
+// ObserveReservedResources sets the reserved amount of a resource which is still kept in the quota of a secondary root.
+func (m *ConfigMetrics) ObserveReservedResources(resource, rootNS, secondaryRoot string, value float64) {
+	m.set(reservedResources, prometheus.Labels{
 		"resource":                 resource,
 		"root_namespace":           rootNS,
 		"secondary_root_namespace": secondaryRoot,
-	}).Set(value)
+	}, value)
+}
+
+// ObserveReservationAge sets the age in seconds of the oldest active reservation of a secondary root.
+func (m *ConfigMetrics) ObserveReservationAge(rootNS, secondaryRoot string, value float64) {
+	m.set(reservationAge, prometheus.Labels{
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+	}, value)
+}
+
+// ObserveNodeGroupNodes sets the number of nodes matched by the node group of a secondary root.
+func (m *ConfigMetrics) ObserveNodeGroupNodes(rootNS, secondaryRoot string, value float64) {
+	m.set(nodeGroupNodes, prometheus.Labels{
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+	}, value)
+}
+
+// ObserveOverlappingNodes sets the number of nodes selected by more than one node group of the NodeQuotaConfig.
+func (m *ConfigMetrics) ObserveOverlappingNodes(value float64) {
+	m.set(overlappingNodes, prometheus.Labels{
+		"namespace":       m.namespace,
+		"nodequotaconfig": m.name,
+	}, value)
+}
+
+// ObserveUnassignedNodes sets the number of nodes not selected by any node group of the NodeQuotaConfig.
+func (m *ConfigMetrics) ObserveUnassignedNodes(value float64) {
+	m.set(unassignedNodes, prometheus.Labels{
+		"namespace":       m.namespace,
+		"nodequotaconfig": m.name,
+	}, value)
+}
+
+// ObserveUnassignedAllocatable sets the allocatable amount of a resource of the nodes not selected by any node group.
+func (m *ConfigMetrics) ObserveUnassignedAllocatable(resource string, value float64) {
+	m.set(unassignedAllocatable, prometheus.Labels{
+		"namespace":       m.namespace,
+		"nodequotaconfig": m.name,
+		"resource":        resource,
+	}, value)
+}
+
+// Commit deletes the series which were set in the previous update of the NodeQuotaConfig and were not set in this one,
+// along with the counters and histograms of the roots and secondary roots which no longer have any series.
+func (m *ConfigMetrics) Commit() {
+	ownedSeriesLock.Lock()
+	defer ownedSeriesLock.Unlock()
+
+	key := configKey(m.namespace, m.name)
+	previous := ownedSeries[key]
+	for seriesKey, owned := range previous {
+		if _, ok := m.observed[seriesKey]; !ok {
+			owned.gauge.Delete(owned.labels)
+		}
+	}
+
+	current := ownedRoots(m.observed)
+	for rootNS, secondaryRoots := range ownedRoots(previous) {
+		if _, ok := current[rootNS]; !ok {
+			deleteRootSeries(rootNS)
+			continue
+		}
+		for secondaryRoot := range secondaryRoots {
+			if _, ok := current[rootNS][secondaryRoot]; !ok {
+				deleteSecondaryRootSeries(rootNS, secondaryRoot)
+			}
+		}
+	}
+	ownedSeries[key] = m.observed
+}
+
+// DeleteConfigMetrics deletes all the series of a NodeQuotaConfig, along with the counters and histograms of its roots
+// and secondary roots.
+func DeleteConfigMetrics(namespace, config string) {
+	ownedSeriesLock.Lock()
+	defer ownedSeriesLock.Unlock()
+
+	key := configKey(namespace, config)
+	for _, owned := range ownedSeries[key] {
+		owned.gauge.Delete(owned.labels)
+	}
+	for rootNS := range ownedRoots(ownedSeries[key]) {
+		deleteRootSeries(rootNS)
+	}
+	delete(ownedSeries, key)
+}
+
+// ownedRoots returns the secondary roots of every root which the given series are labeled with.
+func ownedRoots(owned map[string]series) map[string]map[string]bool {
+	roots := map[string]map[string]bool{}
+	for _, s := range owned {
+		rootNS, ok := s.labels["root_namespace"]
+		if !ok {
+			continue
+		}
+		if roots[rootNS] == nil {
+			roots[rootNS] = map[string]bool{}
+		}
+		roots[rootNS][s.labels["secondary_root_namespace"]] = true
+	}
+	return roots
+}
+
+// deleteRootSeries deletes the counters and histograms of a root and of all its secondary roots.
+func deleteRootSeries(rootNS string) {
+	labels := prometheus.Labels{"root_namespace": rootNS}
+	quotaWrites.DeletePartialMatch(labels)
+	quotaWriteConflicts.DeletePartialMatch(labels)
+	reservationEvents.DeletePartialMatch(labels)
+	calculationDuration.DeletePartialMatch(labels)
+}
+
+// deleteSecondaryRootSeries deletes the counters of a secondary root.
+func deleteSecondaryRootSeries(rootNS, secondaryRoot string) {
+	labels := prometheus.Labels{"root_namespace": rootNS, "secondary_root_namespace": secondaryRoot}
+	quotaWrites.DeletePartialMatch(labels)
+	quotaWriteConflicts.DeletePartialMatch(labels)
+	reservationEvents.DeletePartialMatch(labels)
+}
+
+// configKey returns the key of a NodeQuotaConfig in ownedSeries.
+func configKey(namespace, config string) string {
+	return fmt.Sprintf("%s/%s", namespace, config)
+}
+
+// ObserveQuotaWrite counts an attempted write of the quota of a root or a secondary root by its result.
+// The secondary root is empty for the quota of the root itself.
+func ObserveQuotaWrite(rootNS, secondaryRoot string, succeeded bool) {
+	result := "succeeded"
+	if !succeeded {
+		result = "failed"
+	}
+	quotaWrites.With(prometheus.Labels{
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+		"result":                   result,
+	}).Inc()
+}
+
+// ObserveQuotaWriteConflict counts a write of the quota of a root or a secondary root which conflicted with another change.
+func ObserveQuotaWriteConflict(rootNS, secondaryRoot string) {
+	quotaWriteConflicts.With(prometheus.Labels{
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+	}).Inc()
+}
+
+// ObserveReservationEvent counts a reservation of a secondary root which was started, updated, released or expired.
+func ObserveReservationEvent(event, rootNS, secondaryRoot string) {
+	reservationEvents.With(prometheus.Labels{
+		"root_namespace":           rootNS,
+		"secondary_root_namespace": secondaryRoot,
+		"event":                    event,
+	}).Inc()
+}
+
+// ObserveCalculationDuration observes the duration in seconds of the calculation of the quotas of a root.
+func ObserveCalculationDuration(rootNS string, value float64) {
+	calculationDuration.With(prometheus.Labels{
+		"root_namespace": rootNS,
+	}).Observe(value)
 }
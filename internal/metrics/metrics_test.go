@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigMetricsCommit(t *testing.T) {
+	metrics := NewConfigMetrics("default", "config")
+	metrics.ObserveNodeGroupNodes("cluster-root", "gpu", 3)
+	metrics.ObserveNodeGroupNodes("cluster-root", "cpu", 5)
+	metrics.ObserveOverlappingNodes(1)
+	metrics.Commit()
+	ObserveQuotaWrite("cluster-root", "cpu", true)
+	ObserveQuotaWrite("cluster-root", "", true)
+	assert.Equal(t, 2, testutil.CollectAndCount(nodeGroupNodes))
+	assert.Equal(t, 2, testutil.CollectAndCount(quotaWrites))
+
+	// the cpu secondary root was removed, so its series are deleted while the series of the root are kept
+	metrics = NewConfigMetrics("default", "config")
+	metrics.ObserveNodeGroupNodes("cluster-root", "gpu", 4)
+	metrics.ObserveOverlappingNodes(0)
+	metrics.Commit()
+	assert.Equal(t, 1, testutil.CollectAndCount(nodeGroupNodes))
+	assert.Equal(t, 4.0, testutil.ToFloat64(nodeGroupNodes.WithLabelValues("cluster-root", "gpu")))
+	assert.Equal(t, 1, testutil.CollectAndCount(quotaWrites))
+	assert.Equal(t, 1, testutil.CollectAndCount(overlappingNodes))
+
+	DeleteConfigMetrics("default", "config")
+	assert.Equal(t, 0, testutil.CollectAndCount(nodeGroupNodes))
+	assert.Equal(t, 0, testutil.CollectAndCount(quotaWrites))
+	assert.Equal(t, 0, testutil.CollectAndCount(overlappingNodes))
+}
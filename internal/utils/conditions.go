@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReasonQuotasSynced is set when the quotas of all the roots and secondary roots were calculated and written.
+	ReasonQuotasSynced = "QuotasSynced"
+	// ReasonUpdatesDisabled is set when the quotas were calculated but not written, since updates are disabled.
+	ReasonUpdatesDisabled = "UpdatesDisabled"
+	// ReasonPaused is set when the quotas were calculated but the writes of some or all of them are paused.
+	ReasonPaused = "Paused"
+	// ReasonDryRun is set when the quotas were calculated and the planned changes are shown, since the config is in DryRun mode.
+	ReasonDryRun = "DryRun"
+	// ReasonCalculationFailed is set when the quota of a secondary root could not be calculated.
+	ReasonCalculationFailed = "CalculationFailed"
+	// ReasonUpdateFailed is set when the quota of a root or a secondary root could not be written.
+	ReasonUpdateFailed = "UpdateFailed"
+	// ReasonAsExpected is set when a condition which signals a problem is not active.
+	ReasonAsExpected = "AsExpected"
+	// ReasonResourcesReserved is set when the resources of removed nodes are reserved.
+	ReasonResourcesReserved = "ResourcesReserved"
+	// ReasonNoReservations is set when no resources are reserved.
+	ReasonNoReservations = "NoReservations"
+	// ReasonConflicted is set when roots or secondary roots of the NodeQuotaConfig are managed by another NodeQuotaConfig.
+	ReasonConflicted = "Conflicted"
+	// ReasonNodesOverlapping is set when nodes are selected by more than one node group.
+	ReasonNodesOverlapping = "NodesOverlapping"
+	// ReasonNodesSettling is set when the nodes of the node groups changed and the recalculation waits for the settle window.
+	ReasonNodesSettling = "NodesSettling"
+	// ReasonShrinkRefused is set when the shrink of secondary roots below what their children were allocated was refused.
+	ReasonShrinkRefused = "ShrinkRefused"
+	// ReasonShrinkClamped is set when the shrink of secondary roots was clamped to what their children were allocated.
+	ReasonShrinkClamped = "ShrinkClamped"
+	// ReasonFieldConflict is set when controlled resources of quotas weren't written, since they are owned by other field managers.
+	ReasonFieldConflict = "FieldConflict"
+)
+
+// SetSyncedConditions sets the Ready, Synced and Degraded conditions after the quotas were calculated and written successfully.
+// When updates are disabled nothing is written, so the Synced condition is false while the NodeQuotaConfig is still ready.
+func SetSyncedConditions(config *danav1alpha1.NodeQuotaConfig, updatesDisabled bool) {
+	reason := ReasonQuotasSynced
+	message := "the quotas of all the roots and secondary roots are synced"
+	syncedStatus := metav1.ConditionTrue
+	if updatesDisabled {
+		reason = ReasonUpdatesDisabled
+		message = "the quotas of all the roots and secondary roots were calculated, updates are disabled"
+		syncedStatus = metav1.ConditionFalse
+	} else if config.Spec.Mode == danav1alpha1.ConfigModeDryRun {
+		reason = ReasonDryRun
+		message = "the quotas of all the roots and secondary roots were calculated, the planned changes are shown in the status"
+	} else if IsConfigPaused(*config) {
+		reason = ReasonPaused
+		message = "the quotas of all the roots and secondary roots were calculated, the NodeQuotaConfig is paused and the planned changes are shown in the status"
+	} else if paused := PausedNodeGroups(*config); len(paused) > 0 {
+		reason = ReasonPaused
+		message = fmt.Sprintf("the quotas of all the roots and secondary roots were calculated, node groups %s are paused and their planned changes are shown in the status", strings.Join(paused, ", "))
+	}
+
+	setCondition(config, danav1alpha1.ConditionTypeReady, metav1.ConditionTrue, reason, message)
+	setCondition(config, danav1alpha1.ConditionTypeSynced, syncedStatus, reason, message)
+	setCondition(config, danav1alpha1.ConditionTypeDegraded, metav1.ConditionFalse, ReasonAsExpected, "all the roots and secondary roots are healthy")
+}
+
+// SetFailedConditions sets the Ready, Synced and Degraded conditions when one or more of the roots or secondary roots failed.
+// It takes the reason of the failure and a message for every failing root or secondary root.
+func SetFailedConditions(config *danav1alpha1.NodeQuotaConfig, reason string, failures []string) {
+	message := strings.Join(failures, "; ")
+
+	setCondition(config, danav1alpha1.ConditionTypeReady, metav1.ConditionFalse, reason, message)
+	setCondition(config, danav1alpha1.ConditionTypeSynced, metav1.ConditionFalse, reason, message)
+	setCondition(config, danav1alpha1.ConditionTypeDegraded, metav1.ConditionTrue, reason, message)
+}
+
+// SetReservationsCondition sets the ReservationsActive condition according to the reserved resources in the status.
+func SetReservationsCondition(config *danav1alpha1.NodeQuotaConfig) {
+	if len(config.Status.ReservedResources) == 0 {
+		setCondition(config, danav1alpha1.ConditionTypeReservationsActive, metav1.ConditionFalse, ReasonNoReservations, "no resources are reserved")
+		return
+	}
+
+	nodes := make([]string, 0, len(config.Status.ReservedResources))
+	for _, reservedResources := range config.Status.ReservedResources {
+		if reservedResources.NodeName == "" {
+			nodes = append(nodes, reservedResources.NodeGroup)
+		} else {
+			nodes = append(nodes, fmt.Sprintf("%s/%s", reservedResources.NodeGroup, reservedResources.NodeName))
+		}
+	}
+	message := fmt.Sprintf("resources of removed nodes are reserved for: %s", strings.Join(nodes, ", "))
+	setCondition(config, danav1alpha1.ConditionTypeReservationsActive, metav1.ConditionTrue, ReasonResourcesReserved, message)
+}
+
+// SetShrinkBlockedCondition sets the ShrinkBlocked condition according to the secondary roots whose shrink was refused or clamped.
+// It takes a description of every protected resource of every secondary root.
+func SetShrinkBlockedCondition(config *danav1alpha1.NodeQuotaConfig, protected []string) {
+	if len(protected) == 0 {
+		setCondition(config, danav1alpha1.ConditionTypeShrinkBlocked, metav1.ConditionFalse, ReasonAsExpected, "no secondary root quota is shrunk below what its children were allocated")
+		return
+	}
+
+	reason := ReasonShrinkClamped
+	if config.Spec.ShrinkPolicy == danav1alpha1.ShrinkPolicyRefuse {
+		reason = ReasonShrinkRefused
+	}
+	setCondition(config, danav1alpha1.ConditionTypeShrinkBlocked, metav1.ConditionTrue, reason, strings.Join(protected, "; "))
+}
+
+// SetFieldsConflictedCondition sets the FieldsConflicted condition according to the quotas whose controlled resources
+// are owned by other field managers.
+// It takes a description of the conflicting fields of every quota.
+func SetFieldsConflictedCondition(config *danav1alpha1.NodeQuotaConfig, conflicts []string) {
+	if len(conflicts) == 0 {
+		setCondition(config, danav1alpha1.ConditionTypeFieldsConflicted, metav1.ConditionFalse, ReasonAsExpected, "no controlled resource of the quotas is owned by another field manager")
+		return
+	}
+	setCondition(config, danav1alpha1.ConditionTypeFieldsConflicted, metav1.ConditionTrue, ReasonFieldConflict, strings.Join(conflicts, "; "))
+}
+
+// setCondition sets a condition of the given type in the NodeQuotaConfig status.
+// The LastTransitionTime of the condition is only changed when its status changes.
+func setCondition(config *danav1alpha1.NodeQuotaConfig, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&config.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: config.Generation,
+	})
+}
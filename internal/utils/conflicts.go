@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// FindConfigConflicts lists the NodeQuotaConfigs and finds the roots and secondary roots of the NodeQuotaConfig
+// which are managed by another NodeQuotaConfig.
+func FindConfigConflicts(ctx context.Context, r client.Client, config danav1alpha1.NodeQuotaConfig) ([]danav1alpha1.ConfigConflict, error) {
+	configList := danav1alpha1.NodeQuotaConfigList{}
+	if err := r.List(ctx, &configList); err != nil {
+		return nil, fmt.Errorf("failed to list the NodeQuotaConfigs: %w", err)
+	}
+	return ConfigConflicts(config, configList.Items), nil
+}
+
+// ConfigConflicts finds the roots and secondary roots of the NodeQuotaConfig which are also claimed by one of the given
+// NodeQuotaConfigs that is older than it. Every root and secondary root is managed by the oldest NodeQuotaConfig which
+// claims it, and a root which is managed by another NodeQuotaConfig is reported without its secondary roots.
+// NodeQuotaConfigs in DryRun mode never write the quotas, so they neither claim roots and secondary roots nor are
+// conflicted, and can run next to the NodeQuotaConfig which manages the same roots.
+// It returns the conflicts sorted by root and secondary root.
+func ConfigConflicts(config danav1alpha1.NodeQuotaConfig, configs []danav1alpha1.NodeQuotaConfig) []danav1alpha1.ConfigConflict {
+	if config.Spec.Mode == danav1alpha1.ConfigModeDryRun {
+		return nil
+	}
+
+	var older []danav1alpha1.NodeQuotaConfig
+	for _, other := range configs {
+		if other.Spec.Mode == danav1alpha1.ConfigModeDryRun {
+			continue
+		}
+		if configName(other) != configName(config) && configPrecedes(other, config) {
+			older = append(older, other)
+		}
+	}
+	sort.Slice(older, func(i, j int) bool { return configPrecedes(older[i], older[j]) })
+
+	var conflicts []danav1alpha1.ConfigConflict
+	for _, root := range config.Spec.Roots {
+		if owner := findRootOwner(older, root.RootNamespace); owner != "" {
+			conflicts = append(conflicts, danav1alpha1.ConfigConflict{RootNamespace: root.RootNamespace, ManagedBy: owner})
+			continue
+		}
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if owner := findSecondaryRootOwner(older, secondaryRoot.Name); owner != "" {
+				conflicts = append(conflicts, danav1alpha1.ConfigConflict{RootNamespace: root.RootNamespace, SecondaryRoot: secondaryRoot.Name, ManagedBy: owner})
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].RootNamespace != conflicts[j].RootNamespace {
+			return conflicts[i].RootNamespace < conflicts[j].RootNamespace
+		}
+		return conflicts[i].SecondaryRoot < conflicts[j].SecondaryRoot
+	})
+	return conflicts
+}
+
+// ShadowsManagingConfig checks if the NodeQuotaConfig is in DryRun mode and claims a root or a secondary root which is
+// also claimed by one of the given NodeQuotaConfigs that writes the quotas.
+func ShadowsManagingConfig(config danav1alpha1.NodeQuotaConfig, configs []danav1alpha1.NodeQuotaConfig) bool {
+	if config.Spec.Mode != danav1alpha1.ConfigModeDryRun {
+		return false
+	}
+	for _, other := range configs {
+		if configName(other) != configName(config) && other.Spec.Mode != danav1alpha1.ConfigModeDryRun && ClaimsOverlap(config, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimsOverlap checks if the two NodeQuotaConfigs claim a common root or secondary root.
+func ClaimsOverlap(config, other danav1alpha1.NodeQuotaConfig) bool {
+	others := []danav1alpha1.NodeQuotaConfig{other}
+	for _, root := range config.Spec.Roots {
+		if findRootOwner(others, root.RootNamespace) != "" {
+			return true
+		}
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if findSecondaryRootOwner(others, secondaryRoot.Name) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findRootOwner returns the name of the first NodeQuotaConfig which claims the root, or an empty string if none does.
+func findRootOwner(configs []danav1alpha1.NodeQuotaConfig, rootNamespace string) string {
+	for _, config := range configs {
+		for _, root := range config.Spec.Roots {
+			if root.RootNamespace == rootNamespace {
+				return configName(config)
+			}
+		}
+	}
+	return ""
+}
+
+// findSecondaryRootOwner returns the name of the first NodeQuotaConfig which claims the secondary root, or an empty string
+// if none does. Secondary roots are claimed by name under any root, since the name of a subnamespace is unique in the cluster.
+func findSecondaryRootOwner(configs []danav1alpha1.NodeQuotaConfig, secondaryRoot string) string {
+	for _, config := range configs {
+		for _, root := range config.Spec.Roots {
+			for _, nodeGroup := range root.SecondaryRoots {
+				if nodeGroup.Name == secondaryRoot {
+					return configName(config)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// configPrecedes checks if the first NodeQuotaConfig is older than the second one. A NodeQuotaConfig which is being created
+// is the newest, and between NodeQuotaConfigs created at the same time, the one with the lower namespace/name is older.
+func configPrecedes(config, other danav1alpha1.NodeQuotaConfig) bool {
+	if config.CreationTimestamp.IsZero() != other.CreationTimestamp.IsZero() {
+		return other.CreationTimestamp.IsZero()
+	}
+	if !config.CreationTimestamp.Equal(&other.CreationTimestamp) {
+		return config.CreationTimestamp.Before(&other.CreationTimestamp)
+	}
+	return configName(config) < configName(other)
+}
+
+// configName returns the name of the NodeQuotaConfig as namespace/name.
+func configName(config danav1alpha1.NodeQuotaConfig) string {
+	return fmt.Sprintf("%s/%s", config.Namespace, config.Name)
+}
+
+// DescribeConflict describes a root or a secondary root which is managed by another NodeQuotaConfig.
+func DescribeConflict(conflict danav1alpha1.ConfigConflict) string {
+	if conflict.SecondaryRoot == "" {
+		return fmt.Sprintf("root %s is managed by NodeQuotaConfig %s", conflict.RootNamespace, conflict.ManagedBy)
+	}
+	return fmt.Sprintf("secondary root %s is managed by NodeQuotaConfig %s", conflict.SecondaryRoot, conflict.ManagedBy)
+}
+
+// SetConflictedConditions sets the Conflicted condition according to the conflicts in the status. When the NodeQuotaConfig
+// is conflicted, the Ready and Synced conditions are set as well, since the NodeQuotaConfig doesn't write any quota.
+func SetConflictedConditions(config *danav1alpha1.NodeQuotaConfig) {
+	if len(config.Status.Conflicts) == 0 {
+		setCondition(config, danav1alpha1.ConditionTypeConflicted, metav1.ConditionFalse, ReasonAsExpected, "all the roots and secondary roots are managed by this NodeQuotaConfig")
+		return
+	}
+
+	descriptions := make([]string, 0, len(config.Status.Conflicts))
+	for _, conflict := range config.Status.Conflicts {
+		descriptions = append(descriptions, DescribeConflict(conflict))
+	}
+	message := strings.Join(descriptions, "; ")
+	setCondition(config, danav1alpha1.ConditionTypeConflicted, metav1.ConditionTrue, ReasonConflicted, message)
+	setCondition(config, danav1alpha1.ConditionTypeReady, metav1.ConditionFalse, ReasonConflicted, message)
+	setCondition(config, danav1alpha1.ConditionTypeSynced, metav1.ConditionFalse, ReasonConflicted, message)
+}
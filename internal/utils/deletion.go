@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	danav1 "github.com/dana-team/hns/api/v1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// SnapshotQuotas takes a snapshot of the controlled resources of the quotas of the root and its secondary roots which
+// have no snapshot yet. It is called before the quotas are written, so the snapshots hold the quotas from before
+// the NodeQuotaConfig first managed them. A quota which the plugin already wrote, e.g. by a NodeQuotaConfig which
+// existed before the snapshots were added, is marked as managed before, since its snapshot holds the quota of the plugin.
+func SnapshotQuotas(ctx context.Context, r client.Client, rootSubnamespace danav1alpha1.SubnamespacesRoots, config *danav1alpha1.NodeQuotaConfig) error {
+	if findQuotaSnapshot(config.Status.QuotaSnapshots, rootSubnamespace.RootNamespace, "") == nil {
+		rootRQ, err := GetRootQuota(r, ctx, rootSubnamespace.RootNamespace)
+		if err != nil {
+			return fmt.Errorf("failed to get the resourceQuota of root %s: %w", rootSubnamespace.RootNamespace, err)
+		}
+		addQuotaSnapshot(config, rootSubnamespace.RootNamespace, "", rootRQ.Spec.Hard, isManagedByPlugin(&rootRQ))
+	}
+
+	for _, secondaryRoot := range rootSubnamespace.SecondaryRoots {
+		if findQuotaSnapshot(config.Status.QuotaSnapshots, rootSubnamespace.RootNamespace, secondaryRoot.Name) != nil {
+			continue
+		}
+		sns := danav1.Subnamespace{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: rootSubnamespace.RootNamespace, Name: secondaryRoot.Name}, &sns); err != nil {
+			return fmt.Errorf("failed to get secondary root %s: %w", secondaryRoot.Name, err)
+		}
+		addQuotaSnapshot(config, rootSubnamespace.RootNamespace, secondaryRoot.Name, sns.Spec.ResourceQuotaSpec.Hard, isManagedByPlugin(&sns))
+	}
+	return nil
+}
+
+// isManagedByPlugin checks if the quota was written by the plugin and wasn't orphaned by a deleted NodeQuotaConfig since.
+// The quota of an orphaned object is kept from before the NodeQuotaConfig which manages it now, so it can be restored.
+func isManagedByPlugin(object client.Object) bool {
+	if _, ok := object.GetAnnotations()[danav1alpha1.UnmanagedAnnotation]; ok {
+		return false
+	}
+	for _, managedFields := range object.GetManagedFields() {
+		if managedFields.Manager == FieldManager || managedFields.Manager == ReleasedFieldManager {
+			return true
+		}
+	}
+	return false
+}
+
+// addQuotaSnapshot adds a snapshot of the controlled resources of a quota to the status of the NodeQuotaConfig.
+func addQuotaSnapshot(config *danav1alpha1.NodeQuotaConfig, rootNamespace, secondaryRoot string, hard v1.ResourceList, managedBefore bool) {
+	config.Status.QuotaSnapshots = append(config.Status.QuotaSnapshots, danav1alpha1.QuotaSnapshot{
+		RootNamespace: rootNamespace,
+		SecondaryRoot: secondaryRoot,
+		Hard:          filterUncontrolledResources(hard, config.Spec.ControlledResources),
+		SnapshotTime:  metav1.Now(),
+		ManagedBefore: managedBefore,
+	})
+}
+
+// findQuotaSnapshot returns the snapshot of the given root and secondary root, or nil if it isn't found.
+func findQuotaSnapshot(snapshots []danav1alpha1.QuotaSnapshot, rootNamespace, secondaryRoot string) *danav1alpha1.QuotaSnapshot {
+	for i, snapshot := range snapshots {
+		if snapshot.RootNamespace == rootNamespace && snapshot.SecondaryRoot == secondaryRoot {
+			return &snapshots[i]
+		}
+	}
+	return nil
+}
+
+// DeleteStaleQuotaSnapshots removes the snapshots of roots and secondary roots which are no longer part of the NodeQuotaConfig.
+func DeleteStaleQuotaSnapshots(config *danav1alpha1.NodeQuotaConfig) {
+	var snapshots []danav1alpha1.QuotaSnapshot
+	for _, snapshot := range config.Status.QuotaSnapshots {
+		if isManagedQuota(*config, snapshot.RootNamespace, snapshot.SecondaryRoot) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	config.Status.QuotaSnapshots = snapshots
+}
+
+// isManagedQuota checks if the root, or the secondary root when it is set, is part of the NodeQuotaConfig.
+func isManagedQuota(config danav1alpha1.NodeQuotaConfig, rootNamespace, secondaryRoot string) bool {
+	for _, root := range config.Spec.Roots {
+		if root.RootNamespace != rootNamespace {
+			continue
+		}
+		if secondaryRoot == "" {
+			return true
+		}
+		for _, nodeGroup := range root.SecondaryRoots {
+			if nodeGroup.Name == secondaryRoot {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// restoreHard returns the hard quota with the controlled resources set back to the snapshot. Controlled resources
+// which are not in the snapshot are removed, and resources which are not controlled are kept as they are.
+func restoreHard(hard v1.ResourceList, snapshot danav1alpha1.QuotaSnapshot, controlledResources []string) v1.ResourceList {
+	restored := v1.ResourceList{}
+	for resourceName, quantity := range hard {
+		restored[resourceName] = quantity
+	}
+	for _, resourceName := range controlledResources {
+		delete(restored, v1.ResourceName(resourceName))
+	}
+	for resourceName, quantity := range snapshot.Hard {
+		restored[resourceName] = quantity
+	}
+	return restored
+}
+
+// RestoreQuotaSnapshots writes back the snapshots of the quotas of the NodeQuotaConfig. The secondary roots are restored
+// before the roots, and the quotas which failed to be restored are retried once after the rest, since a quota may only
+// be restored after the quotas of its parent or its children were. Roots and secondary roots which no longer exist are skipped.
+func RestoreQuotaSnapshots(ctx context.Context, r client.Client, recorder record.EventRecorder, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) error {
+	var ordered []danav1alpha1.QuotaSnapshot
+	for _, snapshot := range config.Status.QuotaSnapshots {
+		if snapshot.SecondaryRoot != "" {
+			ordered = append(ordered, snapshot)
+		}
+	}
+	for _, snapshot := range config.Status.QuotaSnapshots {
+		if snapshot.SecondaryRoot == "" {
+			ordered = append(ordered, snapshot)
+		}
+	}
+
+	var failed []danav1alpha1.QuotaSnapshot
+	for _, snapshot := range ordered {
+		if err := restoreQuotaSnapshot(ctx, r, recorder, config, snapshot, logger); err != nil {
+			failed = append(failed, snapshot)
+		}
+	}
+	for _, snapshot := range failed {
+		if err := restoreQuotaSnapshot(ctx, r, recorder, config, snapshot, logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreQuotaSnapshot writes back the snapshot of a single root or secondary root quota.
+// A snapshot which was taken after the plugin wrote the quota is skipped, and the skip is recorded.
+func restoreQuotaSnapshot(ctx context.Context, r client.Client, recorder record.EventRecorder, config *danav1alpha1.NodeQuotaConfig, snapshot danav1alpha1.QuotaSnapshot, logger logr.Logger) error {
+	var object client.Object
+	var description string
+	var oldResources v1.ResourceList
+	var mutate func()
+	if snapshot.SecondaryRoot == "" {
+		rootRQ := &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: snapshot.RootNamespace, Name: snapshot.RootNamespace}}
+		mutate = func() {
+			oldResources = filterUncontrolledResources(rootRQ.Spec.Hard, config.Spec.ControlledResources)
+			rootRQ.Spec.Hard = restoreHard(rootRQ.Spec.Hard, snapshot, config.Spec.ControlledResources)
+		}
+		object, description = rootRQ, fmt.Sprintf("root %s", snapshot.RootNamespace)
+	} else {
+		sns := &danav1.Subnamespace{ObjectMeta: metav1.ObjectMeta{Namespace: snapshot.RootNamespace, Name: snapshot.SecondaryRoot}}
+		mutate = func() {
+			oldResources = filterUncontrolledResources(sns.Spec.ResourceQuotaSpec.Hard, config.Spec.ControlledResources)
+			sns.Spec.ResourceQuotaSpec.Hard = restoreHard(sns.Spec.ResourceQuotaSpec.Hard, snapshot, config.Spec.ControlledResources)
+		}
+		object, description = sns, fmt.Sprintf("secondary root %s", snapshot.SecondaryRoot)
+	}
+
+	if snapshot.ManagedBefore {
+		logger.Info(fmt.Sprintf("Skipping the restore of the quota of %s, it was already managed when its snapshot was taken", description))
+		message := fmt.Sprintf("quota of %s was left as it is, it was already managed by the plugin when NodeQuotaConfig %s took its snapshot, so the quota from before is unknown",
+			description, configName(*config))
+		RecordEvent(recorder, v1.EventTypeWarning, EventReasonQuotaRestoreSkipped, message, config)
+		return nil
+	}
+
+	logger.Info(fmt.Sprintf("Restoring the quota of %s", description))
+	if err := patchQuota(ctx, r, object, mutate, snapshot.RootNamespace, snapshot.SecondaryRoot); err != nil {
+		if errors.IsNotFound(err) {
+			return ignoreNotFound(err, logger, description)
+		}
+		logger.Error(err, fmt.Sprintf("Error restoring the quota of %s", description))
+		return fmt.Errorf("failed to restore the quota of %s: %w", description, err)
+	}
+	message := fmt.Sprintf("quota of %s restored from %s to %s, as before it was managed by NodeQuotaConfig %s", description,
+		FormatResourceList(oldResources), FormatResourceList(snapshot.Hard), configName(*config))
+	RecordEvent(recorder, v1.EventTypeNormal, EventReasonQuotaRestored, message, config, object)
+	return nil
+}
+
+// OrphanQuotas leaves the quotas of the roots and secondary roots of the NodeQuotaConfig as they are, and annotates them
+// as unmanaged. Roots and secondary roots which no longer exist, or which are managed by another NodeQuotaConfig, are skipped.
+func OrphanQuotas(ctx context.Context, r client.Client, recorder record.EventRecorder, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) error {
+	for _, root := range config.Spec.Roots {
+		if isConflicted(*config, root.RootNamespace, "") {
+			continue
+		}
+		rootRQ := &v1.ResourceQuota{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: root.RootNamespace, Name: root.RootNamespace}, rootRQ); err != nil {
+			if err := ignoreNotFound(err, logger, fmt.Sprintf("root %s", root.RootNamespace)); err != nil {
+				return err
+			}
+		} else if err := orphanQuota(ctx, r, recorder, config, rootRQ, fmt.Sprintf("root %s", root.RootNamespace)); err != nil {
+			return err
+		}
+
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if isConflicted(*config, root.RootNamespace, secondaryRoot.Name) {
+				continue
+			}
+			sns := &danav1.Subnamespace{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: root.RootNamespace, Name: secondaryRoot.Name}, sns); err != nil {
+				if err := ignoreNotFound(err, logger, fmt.Sprintf("secondary root %s", secondaryRoot.Name)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := orphanQuota(ctx, r, recorder, config, sns, fmt.Sprintf("secondary root %s", secondaryRoot.Name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// orphanQuota annotates a root ResourceQuota or a secondary root Subnamespace as unmanaged.
+func orphanQuota(ctx context.Context, r client.Client, recorder record.EventRecorder, config *danav1alpha1.NodeQuotaConfig, object client.Object, description string) error {
+	patch := client.MergeFrom(object.DeepCopyObject().(client.Object))
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[danav1alpha1.UnmanagedAnnotation] = configName(*config)
+	object.SetAnnotations(annotations)
+	if err := r.Patch(ctx, object, patch); err != nil {
+		return fmt.Errorf("failed to annotate %s as unmanaged: %w", description, err)
+	}
+
+	message := fmt.Sprintf("quota of %s is no longer managed, NodeQuotaConfig %s was deleted", description, configName(*config))
+	RecordEvent(recorder, v1.EventTypeNormal, EventReasonQuotaOrphaned, message, config, object)
+	return nil
+}
+
+// isConflicted checks if the root, or the secondary root when it is set, is managed by another NodeQuotaConfig.
+func isConflicted(config danav1alpha1.NodeQuotaConfig, rootNamespace, secondaryRoot string) bool {
+	for _, conflict := range config.Status.Conflicts {
+		if conflict.RootNamespace == rootNamespace && (conflict.SecondaryRoot == "" || conflict.SecondaryRoot == secondaryRoot) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreNotFound returns nil if the error is a not found error, which means there is nothing to restore or orphan.
+func ignoreNotFound(err error, logger logr.Logger, description string) error {
+	if errors.IsNotFound(err) {
+		logger.Info(fmt.Sprintf("Skipping %s, it no longer exists", description))
+		return nil
+	}
+	return fmt.Errorf("failed to get %s: %w", description, err)
+}
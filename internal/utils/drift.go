@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// LastSyncedQuotas returns a copy of the quotas in the status of the NodeQuotaConfig if they were written in the last
+// reconciliation, so manual changes made to the quotas since then can be found. It returns nil if the quotas in the
+// status were not written, e.g. when the config is in DryRun mode or the last reconciliation failed.
+func LastSyncedQuotas(config danav1alpha1.NodeQuotaConfig) []danav1alpha1.QuotaStatus {
+	synced := meta.FindStatusCondition(config.Status.Conditions, danav1alpha1.ConditionTypeSynced)
+	if synced == nil || synced.Reason != ReasonQuotasSynced {
+		return nil
+	}
+
+	lastSynced := make([]danav1alpha1.QuotaStatus, len(config.Status.Quotas))
+	for i := range config.Status.Quotas {
+		config.Status.Quotas[i].DeepCopyInto(&lastSynced[i])
+	}
+	return lastSynced
+}
+
+// findQuotaStatus returns the quota status of the given root and secondary root from the list, or nil if it isn't found.
+func findQuotaStatus(quotas []danav1alpha1.QuotaStatus, rootNamespace, secondaryRoot string) *danav1alpha1.QuotaStatus {
+	for i, quotaStatus := range quotas {
+		if quotaStatus.RootNamespace == rootNamespace && quotaStatus.SecondaryRoot == secondaryRoot {
+			return &quotas[i]
+		}
+	}
+	return nil
+}
+
+// syncedQuota returns the quota of the given root and secondary root which was last synced, or nil if it wasn't synced.
+func syncedQuota(lastSynced []danav1alpha1.QuotaStatus, rootNamespace, secondaryRoot string) v1.ResourceList {
+	if quotaStatus := findQuotaStatus(lastSynced, rootNamespace, secondaryRoot); quotaStatus != nil {
+		return quotaStatus.Quota
+	}
+	return nil
+}
+
+// describeDrift compares the current quota with the quota which was last written, and describes every controlled
+// resource which was changed manually since then, along with the quantity it is set back to.
+// It returns the descriptions sorted by the resource name.
+func describeDrift(current, lastSynced, newResources v1.ResourceList) []string {
+	var drifted []string
+	for resourceName, syncedQuantity := range lastSynced {
+		newQuantity, ok := newResources[resourceName]
+		if !ok {
+			continue
+		}
+		currentQuantity, ok := current[resourceName]
+		if ok && currentQuantity.Cmp(syncedQuantity) == 0 {
+			continue
+		}
+
+		changedTo := "removed"
+		if ok {
+			changedTo = fmt.Sprintf("changed to %s", currentQuantity.String())
+		}
+		drifted = append(drifted, fmt.Sprintf("%s was %s, set back to %s", resourceName, changedTo, newQuantity.String()))
+	}
+	sort.Strings(drifted)
+	return drifted
+}
+
+// recordDriftReverted records a QuotaDriftReverted event if controlled resources of a quota were changed manually
+// since the quota was last written, and are set back by writing the new quota.
+func recordDriftReverted(recorder record.EventRecorder, description string, current, newResources v1.ResourceList, lastSynced *danav1alpha1.QuotaStatus, config *danav1alpha1.NodeQuotaConfig, object client.Object) {
+	if lastSynced == nil {
+		return
+	}
+	if drifted := describeDrift(current, lastSynced.Quota, newResources); len(drifted) > 0 {
+		message := fmt.Sprintf("manual change of the quota of %s was reverted: %s", description, strings.Join(drifted, ", "))
+		RecordEvent(recorder, v1.EventTypeWarning, EventReasonQuotaDriftReverted, message, config, object)
+	}
+}
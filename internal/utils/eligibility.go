@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// filterEligibleNodes splits the nodes of a node group according to its eligibility rules.
+// It returns the eligible nodes and the excluded nodes, along with the reason each of them was excluded.
+func filterEligibleNodes(nodes v1.NodeList, eligibility *danav1alpha1.NodeEligibility) (v1.NodeList, []v1.Node, []string) {
+	eligible := v1.NodeList{}
+	var excluded []v1.Node
+	var reasons []string
+	for _, node := range nodes.Items {
+		if reason := excludedNodeReason(node, eligibility); reason != "" {
+			excluded = append(excluded, node)
+			reasons = append(reasons, reason)
+			continue
+		}
+		eligible.Items = append(eligible.Items, node)
+	}
+	return eligible, excluded, reasons
+}
+
+// excludedNodeReason checks the node against the eligibility rules of a node group.
+// It returns the rule which excludes the node, or an empty string if the node is eligible.
+func excludedNodeReason(node v1.Node, eligibility *danav1alpha1.NodeEligibility) string {
+	if eligibility == nil {
+		return ""
+	}
+
+	if eligibility.ExcludeUnschedulable && node.Spec.Unschedulable {
+		return "Unschedulable"
+	}
+
+	for _, rule := range eligibility.ExcludeConditions {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == rule.Type && condition.Status == rule.Status {
+				return fmt.Sprintf("Condition %s=%s", condition.Type, condition.Status)
+			}
+		}
+	}
+
+	for _, rule := range eligibility.ExcludeTaints {
+		for _, taint := range node.Spec.Taints {
+			if isTaintMatching(taint, rule) {
+				return fmt.Sprintf("Taint %s", taint.ToString())
+			}
+		}
+	}
+	return ""
+}
+
+// isTaintMatching checks if the taint matches the key of the rule, and its value and effect if they are set.
+func isTaintMatching(taint v1.Taint, rule danav1alpha1.TaintRule) bool {
+	return taint.Key == rule.Key &&
+		(rule.Value == "" || taint.Value == rule.Value) &&
+		(rule.Effect == "" || taint.Effect == rule.Effect)
+}
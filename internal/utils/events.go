@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+	nqsmetrics "github.com/dana-team/hns-nqs-plugin/internal/metrics"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// EventReasonQuotaUpdated is used when the quota of a root or a secondary root was changed.
+	EventReasonQuotaUpdated = "QuotaUpdated"
+	// EventReasonReservationStarted is used when the resources of a removed node started to be reserved.
+	EventReasonReservationStarted = "ReservationStarted"
+	// EventReasonReservationUpdated is used when a reservation was extended on request.
+	EventReasonReservationUpdated = "ReservationUpdated"
+	// EventReasonReservationReleased is used when the reserved resources were released since the node came back or on request.
+	EventReasonReservationReleased = "ReservationReleased"
+	// EventReasonReservationExpired is used when the reserved resources were removed after reservedHoursToLive passed.
+	EventReasonReservationExpired = "ReservationExpired"
+	// EventReasonQuotaRestored is used when the quota from before the NodeQuotaConfig managed it was written back on its deletion.
+	EventReasonQuotaRestored = "QuotaRestored"
+	// EventReasonQuotaRestoreSkipped is used when a quota wasn't restored, since its snapshot was taken after the plugin wrote it.
+	EventReasonQuotaRestoreSkipped = "QuotaRestoreSkipped"
+	// EventReasonQuotaOrphaned is used when a quota was left as it is and annotated as unmanaged on the deletion of the NodeQuotaConfig.
+	EventReasonQuotaOrphaned = "QuotaOrphaned"
+	// EventReasonQuotaDriftReverted is used when a manual change of the controlled resources of a quota was reverted.
+	EventReasonQuotaDriftReverted = "QuotaDriftReverted"
+	// EventReasonQuotaRebalanced is used when the quota of a child of a secondary root was trimmed after the secondary root lost capacity.
+	EventReasonQuotaRebalanced = "QuotaRebalanced"
+	// EventReasonShrinkRefused is used when the shrink of a secondary root quota below what its children were allocated was refused.
+	EventReasonShrinkRefused = "ShrinkRefused"
+	// EventReasonShrinkClamped is used when the shrink of a secondary root quota was clamped to what its children were allocated.
+	EventReasonShrinkClamped = "ShrinkClamped"
+	// EventReasonQuotaBelowAllocated is used when the quota of a secondary root was shrunk below what its children were allocated.
+	EventReasonQuotaBelowAllocated = "QuotaBelowAllocated"
+	// EventReasonInvalidReservationRequest is used when the annotations requesting to release or extend reservations are malformed.
+	EventReasonInvalidReservationRequest = "InvalidReservationRequest"
+)
+
+// RecordEvent records an event on every one of the given objects.
+// It does nothing if the recorder is not set.
+func RecordEvent(recorder record.EventRecorder, eventType, reason, message string, objects ...runtime.Object) {
+	if recorder == nil {
+		return
+	}
+	for _, object := range objects {
+		recorder.Event(object, eventType, reason, message)
+	}
+}
+
+// RecordReservationEvent records an event about a reservation on the NodeQuotaConfig and on the other given objects, and
+// counts it in the reservation events metric of the node group.
+// Nothing is recorded or counted in DryRun mode, since the reservations of the NodeQuotaConfig are only planned.
+func RecordReservationEvent(recorder record.EventRecorder, config *danav1alpha1.NodeQuotaConfig, eventType, reason, message, nodeGroup string, objects ...runtime.Object) {
+	if config.Spec.Mode == danav1alpha1.ConfigModeDryRun {
+		return
+	}
+	RecordEvent(recorder, eventType, reason, message, append([]runtime.Object{config}, objects...)...)
+	nqsmetrics.ObserveReservationEvent(reason, RootNamespaceOf(*config, nodeGroup), nodeGroup)
+}
+
+// FormatResourceList formats a resource list as a sorted, comma separated list of name=quantity pairs.
+func FormatResourceList(resources v1.ResourceList) string {
+	if len(resources) == 0 {
+		return "none"
+	}
+	pairs := make([]string, 0, len(resources))
+	for resourceName, quantity := range resources {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", resourceName, quantity.String()))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// DescribeReservation describes the reserved resources and the node they belong to, if known.
+func DescribeReservation(reservedResources danav1alpha1.ReservedResources) string {
+	if reservedResources.NodeName == "" {
+		return FormatResourceList(reservedResources.Resources)
+	}
+	return fmt.Sprintf("%s of node %s", FormatResourceList(reservedResources.Resources), reservedResources.NodeName)
+}
+
+// describeNodeGroupChange describes how the nodes of a node group changed since the previous calculation.
+func describeNodeGroupChange(previous *danav1alpha1.QuotaStatus, current danav1alpha1.QuotaStatus) string {
+	if previous == nil {
+		return fmt.Sprintf("node group %s has %d nodes", current.SecondaryRoot, current.Nodes)
+	}
+
+	diff := current.Nodes - previous.Nodes
+	switch {
+	case diff < 0:
+		return fmt.Sprintf("node group %s shrank by %d nodes", current.SecondaryRoot, -diff)
+	case diff > 0:
+		return fmt.Sprintf("node group %s grew by %d nodes", current.SecondaryRoot, diff)
+	default:
+		return fmt.Sprintf("allocatable resources of node group %s changed", current.SecondaryRoot)
+	}
+}
+
+// areResourceListsEqual checks if both resource lists contain the same resources with the same quantities.
+func areResourceListsEqual(resourcesList v1.ResourceList, resourcesList2 v1.ResourceList) bool {
+	return len(resourcesList) == len(resourcesList2) && isEqualTo(resourcesList, resourcesList2)
+}
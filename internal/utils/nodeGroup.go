@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 
 	danav1 "github.com/dana-team/hns/api/v1"
 	"github.com/go-logr/logr"
@@ -13,6 +14,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
@@ -22,17 +24,49 @@ import (
 // It takes a context, a NodeList containing the nodes, the NodeQuotaConfig, and the node group name.
 // It returns the calculated resource list (v1.ResourceList) for the node group.
 func CalculateNodeGroup(nodes v1.NodeList, config danav1alpha1.NodeQuotaConfig, nodeGroup string, logger logr.Logger) v1.ResourceList {
+	return calculateNodeGroupQuota(nodes, config, nodeGroup, logger).Multiplied
+}
+
+// calculateNodeGroupQuota calculates the resources of a node group and keeps every step of the calculation,
+// so the allocatable resources, the system claim and the multiplied resources can be shown in the status.
+func calculateNodeGroupQuota(nodes v1.NodeList, config danav1alpha1.NodeQuotaConfig, nodeGroup string, logger logr.Logger) danav1alpha1.QuotaStatus {
 	resourceMultiplier := getResourcesMultiplierByNodeGroup(config, nodeGroup)
 	systemResourceClaim := getSystemResourceClaimByNodeGroup(config, nodeGroup)
+	// the claim is always shown in the status, but is subtracted from the quota only when the node group opts in
+	subtractedClaim := map[string]resource.Quantity(nil)
+	if isSubtractingSystemResourceClaim(config, nodeGroup) {
+		subtractedClaim = systemResourceClaim
+	}
+	roundingModes := getRoundingModesByNodeGroup(config, nodeGroup)
+	allocatable := v1.ResourceList{}
+	claimed := v1.ResourceList{}
 	nodeGroupResources := v1.ResourceList{}
+	nodeNames := make([]string, 0, len(nodes.Items))
 	for _, node := range nodes.Items {
-		resources := multiplyResourceList(node.Status.Allocatable, resourceMultiplier, systemResourceClaim, logger)
+		nodeNames = append(nodeNames, node.Name)
+		remaining := subtractResources(node.Status.Allocatable, systemResourceClaim, logr.Discard())
+		resources := multiplyResourceList(node.Status.Allocatable, resourceMultiplier, roundingModes, subtractedClaim, logger)
+		for resourceName, resourceQuantity := range node.Status.Allocatable {
+			addResourcesToList(&allocatable, resourceQuantity, string(resourceName))
+		}
+		for resourceName, resourceQuantity := range subtractTwoResourceList(node.Status.Allocatable, remaining) {
+			addResourcesToList(&claimed, resourceQuantity, string(resourceName))
+		}
 		for resourceName, resourceQuantity := range resources {
 			addResourcesToList(&nodeGroupResources, resourceQuantity, string(resourceName))
 		}
 	}
 
-	return filterUncontrolledResources(nodeGroupResources, config.Spec.ControlledResources)
+	sort.Strings(nodeNames)
+
+	return danav1alpha1.QuotaStatus{
+		SecondaryRoot:       nodeGroup,
+		Nodes:               len(nodes.Items),
+		NodeNames:           nodeNames,
+		Allocatable:         filterUncontrolledResources(allocatable, config.Spec.ControlledResources),
+		SystemResourceClaim: filterUncontrolledResources(claimed, config.Spec.ControlledResources),
+		Multiplied:          filterUncontrolledResources(nodeGroupResources, config.Spec.ControlledResources),
+	}
 }
 
 // getResourcesMultiplierByNodeGroup returns the resourcesMultiplier for the provided node group name.
@@ -48,54 +82,57 @@ func getResourcesMultiplierByNodeGroup(config danav1alpha1.NodeQuotaConfig, node
 	return ResourceMultiplier
 }
 
-// getSystemResourceClaimByNodeGroup retrieves the systemResourceClaim for the specified nodeGroup
-func getSystemResourceClaimByNodeGroup(config danav1alpha1.NodeQuotaConfig, nodeGroupName string) map[string]resource.Quantity {
+// getRoundingModesByNodeGroup retrieves the roundingModes for the specified nodeGroup
+func getRoundingModesByNodeGroup(config danav1alpha1.NodeQuotaConfig, nodeGroupName string) map[string]danav1alpha1.RoundingMode {
 	for _, root := range config.Spec.Roots {
 		for _, group := range root.SecondaryRoots {
 			if group.Name == nodeGroupName {
-				return group.SystemResourceClaim
+				return group.RoundingModes
 			}
 		}
 	}
 	return nil
 }
 
-// getReservedResourcesByGroup retrieves the reserved resources for a specific node group from the NodeQuotaConfig.
-// It takes the node group name and the NodeQuotaConfig.
-// It returns the ReservedResources object (danav1alpha1.ReservedResources) for the node group.
-func getReservedResourcesByGroup(group string, config danav1alpha1.NodeQuotaConfig) danav1alpha1.ReservedResources {
-	if !doesReservedResourceExist(config, group) {
-		return danav1alpha1.ReservedResources{}
-	}
-	for _, resource := range config.Status.ReservedResources {
-		if resource.NodeGroup == group {
-			return resource
+// getSystemResourceClaimByNodeGroup retrieves the systemResourceClaim for the specified nodeGroup
+func getSystemResourceClaimByNodeGroup(config danav1alpha1.NodeQuotaConfig, nodeGroupName string) map[string]resource.Quantity {
+	for _, root := range config.Spec.Roots {
+		for _, group := range root.SecondaryRoots {
+			if group.Name == nodeGroupName {
+				return group.SystemResourceClaim
+			}
 		}
 	}
-	return danav1alpha1.ReservedResources{}
+	return nil
 }
 
-// DeleteExpiredReservedResources removes the expired reserved resources from the NodeQuotaConfig.
-// It takes the NodeQuotaConfig to modify and a logger for logging informational messages.
-func DeleteExpiredReservedResources(config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) {
-	var newReservedResources []danav1alpha1.ReservedResources
-
-	for _, resources := range config.Status.ReservedResources {
-		if isReservedResourceExpired(resources, *config) {
-			logger.Info(fmt.Sprintf("Removed ReservedResources from nodeGroup %s", resources.NodeGroup))
-		} else {
-			newReservedResources = append(newReservedResources, resources)
+// isSubtractingSystemResourceClaim checks if the systemResourceClaim of the specified nodeGroup is subtracted from its nodes
+func isSubtractingSystemResourceClaim(config danav1alpha1.NodeQuotaConfig, nodeGroupName string) bool {
+	for _, root := range config.Spec.Roots {
+		for _, group := range root.SecondaryRoots {
+			if group.Name == nodeGroupName {
+				return group.SubtractSystemResourceClaim
+			}
 		}
 	}
-	config.Status.ReservedResources = newReservedResources
+	return false
 }
 
-// CalculateSecondaryNodeGroup calculates the resource list for a secondary node group based on the provided nodegroup and NodeQuotaConfig.
+// CalculateSecondaryNodeGroup calculates the resources of a secondary node group based on the provided nodegroup and NodeQuotaConfig.
 // It takes a context, a client for making API requests, a nodegroup to calculate resources for, and the NodeQuotaConfig.
-// It returns an error (if any occurred) and the calculated resource list (v1.ResourceList).
-func CalculateSecondaryNodeGroup(ctx context.Context, r client.Client, nodegroup danav1alpha1.NodeGroup, config *danav1alpha1.NodeQuotaConfig) (error, v1.ResourceList) {
+// It returns an error (if any occurred) and the calculation of the node group (danav1alpha1.QuotaStatus),
+// where the Multiplied field holds the calculated resource list.
+// Nodes which are excluded by the eligibility rules of the node group are not part of the calculation,
+// they are listed in the ExcludedNodes field along with the resources they would have added.
+// Nodes which the overlap policy assigned to another node group are not part of the calculation at all,
+// and a catch-all node group only calculates the nodes which no other node group, of this or of another NodeQuotaConfig, selects.
+func CalculateSecondaryNodeGroup(ctx context.Context, r client.Client, nodegroup danav1alpha1.NodeGroup, config *danav1alpha1.NodeQuotaConfig) (error, danav1alpha1.QuotaStatus) {
 	logger, _ := logr.FromContext(ctx)
-	labelSelector := labels.SelectorFromSet(labels.Set(nodegroup.LabelSelector))
+	labelSelector, err := NodeGroupSelector(nodegroup)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("Error parsing the node selector of the nodeGroup %s", nodegroup.Name))
+		return err, danav1alpha1.QuotaStatus{}
+	}
 	listOptions := &client.ListOptions{
 		LabelSelector: labelSelector,
 	}
@@ -103,135 +140,295 @@ func CalculateSecondaryNodeGroup(ctx context.Context, r client.Client, nodegroup
 	nodeList := v1.NodeList{}
 	if err := r.List(ctx, &nodeList, listOptions); err != nil {
 		logger.Error(err, fmt.Sprintf("Error listing the nodes for the nodeGroup %v", nodegroup))
-		return err, v1.ResourceList{}
+		return err, danav1alpha1.QuotaStatus{}
 	}
 
-	nodeResources := CalculateNodeGroup(nodeList, *config, nodegroup.Name, logger)
-	return nil, nodeResources
+	if nodegroup.CatchAll {
+		others, err := listOtherConfigs(ctx, r, *config)
+		if err != nil {
+			logger.Error(err, fmt.Sprintf("Error listing the other NodeQuotaConfigs for the catch-all nodeGroup %s", nodegroup.Name))
+			return err, danav1alpha1.QuotaStatus{}
+		}
+		nodeList = filterLeftoverNodes(nodeList, *config, others)
+	}
+	nodeList = filterAssignedNodes(nodeList, nodegroup.Name, config.Status.OverlappingNodes)
+	eligible, excluded, reasons := filterEligibleNodes(nodeList, nodegroup.Eligibility)
+	groupQuota := calculateNodeGroupQuota(eligible, *config, nodegroup.Name, logger)
+	for i, node := range excluded {
+		groupQuota.ExcludedNodes = append(groupQuota.ExcludedNodes, danav1alpha1.ExcludedNode{
+			Name:      node.Name,
+			Reason:    reasons[i],
+			Resources: calculateNodeGroupQuota(v1.NodeList{Items: []v1.Node{node}}, *config, nodegroup.Name, logger).Multiplied,
+		})
+	}
+	sort.Slice(groupQuota.ExcludedNodes, func(i, j int) bool {
+		return groupQuota.ExcludedNodes[i].Name < groupQuota.ExcludedNodes[j].Name
+	})
+	return nil, groupQuota
 }
 
-// doesReservedResourceExist checks if a reserved resource exists in the NodeQuotaConfig for the given node group name.
-// It takes the NodeQuotaConfig and the node group name to check.
-// It returns a boolean value indicating whether the reserved resource exists or not.
-func doesReservedResourceExist(config danav1alpha1.NodeQuotaConfig, nodeGroupName string) bool {
-	if len(config.Status.ReservedResources) == 0 {
-		return false
+// NodeGroupSelector builds the selector of the nodes of the given node group.
+// The map based LabelSelector and the NodeSelector are combined, so a node must match both of them.
+func NodeGroupSelector(nodegroup danav1alpha1.NodeGroup) (labels.Selector, error) {
+	selector := labels.SelectorFromSet(labels.Set(nodegroup.LabelSelector))
+	if nodegroup.NodeSelector == nil {
+		return selector, nil
+	}
+
+	nodeSelector, err := metav1.LabelSelectorAsSelector(nodegroup.NodeSelector)
+	if err != nil {
+		return nil, err
 	}
-	for _, reservedResources := range config.Status.ReservedResources {
-		if reservedResources.NodeGroup == nodeGroupName {
-			return true
+	requirements, _ := nodeSelector.Requirements()
+	return selector.Add(requirements...), nil
+}
+
+// ConfigTracksNode checks if a change of a node with the given labels may change the status of the NodeQuotaConfig.
+// It takes the NodeQuotaConfigs of the cluster, out of which the other NodeQuotaConfigs are found.
+// A node is tracked when one of the node groups selects it, and a node group with an invalid selector is treated as
+// selecting the node, so changes of the node are never missed. A node which no node group selects is tracked as well,
+// since it is either listed in the unassigned nodes, or received by the catch-all node group when no node group of
+// another NodeQuotaConfig selects it.
+func ConfigTracksNode(config danav1alpha1.NodeQuotaConfig, configs []danav1alpha1.NodeQuotaConfig, nodeLabels map[string]string) bool {
+	for _, root := range config.Spec.Roots {
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if secondaryRoot.CatchAll {
+				continue
+			}
+			selector, err := NodeGroupSelector(secondaryRoot)
+			if err != nil || selector.Matches(labels.Set(nodeLabels)) {
+				return true
+			}
 		}
 	}
-	return false
+	if !hasCatchAllNodeGroup(config) {
+		return true
+	}
+	return !selectedByOtherConfigs(otherConfigs(config, configs), nodeLabels)
 }
 
 // UpdateRootSubnamespace updates the resourceQuota of the rootSubnamespace with the new quantity of resources.
-func UpdateRootSubnamespace(ctx context.Context, rootResources v1.ResourceList, rootSubnamespace danav1alpha1.SubnamespacesRoots, logger logr.Logger, client client.Client) error {
-	rootRQ, err := GetRootQuota(client, ctx, rootSubnamespace.RootNamespace)
-	if err != nil {
-		logger.Error(err, fmt.Sprintf("Error getting the %s resourceQuota", rootSubnamespace.RootNamespace))
-		return err
+// If the quota changed, an event is recorded on the resourceQuota and on the NodeQuotaConfig.
+// If controlled resources were changed manually since the quotas were last synced, the revert is recorded as well.
+// Nothing is written when the NodeQuotaConfig is paused, and paused secondary roots are counted with their current quota.
+// A FieldConflictError is returned when controlled resources of the resourceQuota are owned by other field managers.
+func UpdateRootSubnamespace(ctx context.Context, rootResources v1.ResourceList, rootSubnamespace danav1alpha1.SubnamespacesRoots, logger logr.Logger, client client.Client, recorder record.EventRecorder, config *danav1alpha1.NodeQuotaConfig, lastSynced []danav1alpha1.QuotaStatus) error {
+	if IsConfigPaused(*config) {
+		logger.Info(fmt.Sprintf("Skipping the update of RootSubnamespace %s, the NodeQuotaConfig is paused", rootSubnamespace.RootNamespace))
+		return nil
+	}
+	if IsRootPaused(*config, rootSubnamespace) {
+		rootResources = pausedRootResources(rootSubnamespace, rootResources, *config)
 	}
-	rootRQ.Spec.Hard = patchResourcesToList(rootRQ.Spec.Hard, rootResources)
+
+	// only the controlled resources are owned and written by the plugin, the other resources are left to other managers
+	rootResources = filterUncontrolledResources(rootResources, config.Spec.ControlledResources)
+	rootRQ := v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: rootSubnamespace.RootNamespace, Name: rootSubnamespace.RootNamespace}}
 	logger.Info(fmt.Sprintf("Updating RootSubnamespace %s with new resources", rootSubnamespace.RootNamespace))
-	if err := client.Update(ctx, &rootRQ); err != nil {
+	err := applyQuota(ctx, client, &rootRQ, rootResources, syncedQuota(lastSynced, rootSubnamespace.RootNamespace, ""), isForcingFields(*config), fmt.Sprintf("root %s", rootSubnamespace.RootNamespace), rootSubnamespace.RootNamespace, "")
+	if err != nil {
 		logger.Error(err, fmt.Sprintf("Error updating rootSubnamespace %s", rootSubnamespace.RootNamespace))
 		return err
 	}
+	oldResources := filterResourcesByList(rootRQ.Spec.Hard, rootResources)
+
+	recordDriftReverted(recorder, fmt.Sprintf("root %s", rootSubnamespace.RootNamespace), oldResources, rootResources, findQuotaStatus(lastSynced, rootSubnamespace.RootNamespace, ""), config, &rootRQ)
+	if !areResourceListsEqual(oldResources, rootResources) {
+		message := fmt.Sprintf("quota of root %s changed from %s to %s", rootSubnamespace.RootNamespace, FormatResourceList(oldResources), FormatResourceList(rootResources))
+		RecordEvent(recorder, v1.EventTypeNormal, EventReasonQuotaUpdated, message, config, &rootRQ)
+	}
 	return nil
 }
 
 // UpdateProcessedSecondaryRoots updates the secondaryRoots in the cluster with the new quantity of resources.
 // It takes slice of Subnamespaces that was updated in memory and does API requests to commit the update.
-func UpdateProcessedSecondaryRoots(ctx context.Context, processedSecondaryRoots []danav1.Subnamespace, logger logr.Logger, client client.Client) error {
+// If the quota of a secondaryRoot changed, an event is recorded on the Subnamespace and on the NodeQuotaConfig.
+// If controlled resources were changed manually since the quotas were last synced, the revert is recorded as well.
+// Paused secondary roots and their rebalanced children are skipped.
+// Subnamespaces whose controlled resources are owned by other field managers are skipped as well, and a FieldConflictError
+// is returned after the other subnamespaces were written.
+func UpdateProcessedSecondaryRoots(ctx context.Context, processedSecondaryRoots []danav1.Subnamespace, logger logr.Logger, client client.Client, recorder record.EventRecorder, config *danav1alpha1.NodeQuotaConfig, lastSynced []danav1alpha1.QuotaStatus) error {
+	var fieldConflicts []string
 	for _, sns := range processedSecondaryRoots {
+		// only the rebalanced children of a secondary root have no quota status, and their namespace is the secondary root
+		nodeGroup := sns.Name
+		if getQuotaStatus(*config, sns.Namespace, sns.Name) == nil {
+			nodeGroup = sns.Namespace
+		}
+		if IsNodeGroupPaused(*config, nodeGroup) {
+			logger.Info(fmt.Sprintf("Skipping the update of subnamespace %s, node group %s is paused", sns.Name, nodeGroup))
+			continue
+		}
+
+		// only the controlled resources are owned and written by the plugin, the other resources are left to other managers
+		newResources := filterUncontrolledResources(sns.Spec.ResourceQuotaSpec.Hard, config.Spec.ControlledResources)
 		logger.Info(fmt.Sprintf("Updating secondaryRoot %s with new resources", sns.Name))
-		if err := client.Update(ctx, &sns); err != nil {
+		current := danav1.Subnamespace{ObjectMeta: metav1.ObjectMeta{Namespace: sns.Namespace, Name: sns.Name}}
+		err := applyQuota(ctx, client, &current, newResources, syncedQuota(lastSynced, sns.Namespace, sns.Name), isForcingFields(*config), fmt.Sprintf("subnamespace %s", sns.Name), RootNamespaceOf(*config, nodeGroup), nodeGroup)
+		if conflicts := FieldConflicts(err); conflicts != nil {
+			// the other secondary roots are still written, and the conflicts are reported together
+			logger.Info(fmt.Sprintf("Skipping subnamespace %s, its controlled resources are owned by other field managers: %v", sns.Name, err.Error()))
+			fieldConflicts = append(fieldConflicts, conflicts...)
+			continue
+		}
+		if err != nil {
 			logger.Error(err, fmt.Sprintf("Error updating secondaryRoot %s", sns.Name))
-			return err
+			return fmt.Errorf("failed to update secondary root %s: %w", sns.Name, err)
 		}
-	}
-	return nil
-}
+		oldResources := filterUncontrolledResources(current.Spec.ResourceQuotaSpec.Hard, config.Spec.ControlledResources)
 
-// isReservedResourceExpired checks if a reserved created more than X hours ago, defined by the user in the config CRD.
-func isReservedResourceExpired(reservedResources danav1alpha1.ReservedResources, config danav1alpha1.NodeQuotaConfig) bool {
-	return hoursPassedSinceDate(reservedResources.Timestamp) >= config.Spec.ReservedHoursToLive
-}
-
-// setReservedToConfig sets the reserved resources for a node group in the NodeQuotaConfig.
-// It takes the resource debt (v1.ResourceList) to set, the node group name, the NodeQuotaConfig to modify, and a logger for logging informational messages.
-func setReservedToConfig(debt v1.ResourceList, nodeGroupName string, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) {
-	if doesReservedResourceExist(*config, nodeGroupName) {
-		reservedResources := getReservedResourcesByGroup(nodeGroupName, *config)
-		reservedResources.Resources = debt
-		removeReservedFromConfig(nodeGroupName, config)
-		config.Status.ReservedResources = append(config.Status.ReservedResources, reservedResources)
-		return
-	}
-	config.Status.ReservedResources = append(config.Status.ReservedResources, danav1alpha1.ReservedResources{
-		NodeGroup: nodeGroupName,
-		Resources: debt,
-		Timestamp: metav1.Now(),
-	})
-	logger.Info(fmt.Sprintf("Added ReservedResources to nodeGroup %s", nodeGroupName))
-}
-
-// removeReservedFromConfig removes the reserved resources for a node group from the NodeQuotaConfig.
-// It takes the node group name and the NodeQuotaConfig to modify.
-func removeReservedFromConfig(nodeGroupName string, config *danav1alpha1.NodeQuotaConfig) {
-	index := -1
-	for i, reservedResources := range config.Status.ReservedResources {
-		if reservedResources.NodeGroup == nodeGroupName {
-			index = i
+		recordDriftReverted(recorder, fmt.Sprintf("secondary root %s", sns.Name), oldResources, newResources, findQuotaStatus(lastSynced, sns.Namespace, sns.Name), config, &current)
+		if !areResourceListsEqual(oldResources, newResources) {
+			groupQuota := getQuotaStatus(*config, sns.Namespace, sns.Name)
+			if groupQuota == nil {
+				// only the rebalanced children of a secondary root have no quota status
+				message := fmt.Sprintf("quota of subnamespace %s changed from %s to %s, rebalanced after secondary root %s lost capacity", sns.Name, FormatResourceList(oldResources), FormatResourceList(newResources), sns.Namespace)
+				RecordEvent(recorder, v1.EventTypeNormal, EventReasonQuotaRebalanced, message, config, &current)
+				continue
+			}
+			message := fmt.Sprintf("quota of secondary root %s changed from %s to %s, node group %s has %d nodes", sns.Name, FormatResourceList(oldResources), FormatResourceList(newResources), sns.Name, groupQuota.Nodes)
+			RecordEvent(recorder, v1.EventTypeNormal, EventReasonQuotaUpdated, message, config, &current)
 		}
 	}
-	if index == -1 {
-		return
+	if len(fieldConflicts) > 0 {
+		return &FieldConflictError{Conflicts: fieldConflicts}
 	}
-	config.Status.ReservedResources = slices.Delete(config.Status.ReservedResources, index, index+1)
+	return nil
 }
 
 // ProcessSecondaryRoot processes a secondary root node group and updates the corresponding Subnamespace object and add reserved resources to the config if needed.
 // It takes a context, a client for making API requests, the secondary root node group, the NodeQuotaConfig,
 // the root subnamespace, and a logger for logging informational messages.
-// It returns an error (if any occurred) and the updated Subnamespace object (danav1.Subnamespace).
-func ProcessSecondaryRoot(ctx context.Context, r client.Client, secondaryRoot danav1alpha1.NodeGroup, config *danav1alpha1.NodeQuotaConfig, rootSubnamespace string, logger logr.Logger) (danav1.Subnamespace, bool, error) {
+// It returns the updated Subnamespace object (danav1.Subnamespace), whether the node group has active reservations and an error (if any occurred).
+// The quota of the Subnamespace is the resources of the current nodes plus the reservations of the removed nodes which did not expire yet.
+// Events are recorded on the Subnamespace and on the NodeQuotaConfig when a reservation is started or released.
+func ProcessSecondaryRoot(ctx context.Context, r client.Client, recorder record.EventRecorder, secondaryRoot danav1alpha1.NodeGroup, config *danav1alpha1.NodeQuotaConfig, rootSubnamespace string, logger logr.Logger) (danav1.Subnamespace, bool, error) {
 	sns := danav1.Subnamespace{}
 	if err := r.Get(ctx, types.NamespacedName{Namespace: rootSubnamespace, Name: secondaryRoot.Name}, &sns); err != nil {
 		logger.Error(err, fmt.Sprintf("Error getting the subnamespace %s", secondaryRoot.Name))
 		return sns, false, err
 	}
 
-	err, groupResources := CalculateSecondaryNodeGroup(ctx, r, secondaryRoot, config)
+	err, groupQuota := CalculateSecondaryNodeGroup(ctx, r, secondaryRoot, config)
 	if err != nil {
 		return sns, false, err
 	}
+	groupQuota.RootNamespace = rootSubnamespace
 
-	groupReserved := getReservedResourcesByGroup(secondaryRoot.Name, *config)
-	filteredSNSResources := filterUncontrolledResources(sns.Spec.ResourceQuotaSpec.Hard, config.Spec.ControlledResources)
+	// the previous quota is taken from the previous calculation in the status when possible, so reservations are kept
+	// even if updates are disabled, and a quota which was kept by the shrink policy isn't treated as missing resources
+	previousQuota := filterUncontrolledResources(sns.Spec.ResourceQuotaSpec.Hard, config.Spec.ControlledResources)
+	var previousNodes []string
+	previous := getQuotaStatus(*config, rootSubnamespace, secondaryRoot.Name)
+	if previous != nil {
+		previousQuota = filterUncontrolledResources(MergeTwoResourceList(previous.Multiplied, previous.ReservedResources), config.Spec.ControlledResources)
+		previousNodes = previous.NodeNames
+	}
+	nodeGroupChange := describeNodeGroupChange(previous, groupQuota)
 
-	if isGreaterThan(filteredSNSResources, groupResources) {
-		// one or more nodes removed from cluster
-		debt := subtractTwoResourceList(sns.Spec.ResourceQuotaSpec.Hard, groupResources)
-		filteredDebt := filterUncontrolledResources(debt, config.Spec.ControlledResources)
+	released, started := updateGroupReservations(config, groupQuota, previousQuota, previousNodes)
+	for _, reservedResources := range released {
+		logger.Info(fmt.Sprintf("Released ReservedResources of node %q from nodeGroup %s", reservedResources.NodeName, secondaryRoot.Name))
+		message := fmt.Sprintf("%s; reservation of %s released", nodeGroupChange, DescribeReservation(reservedResources))
+		RecordReservationEvent(recorder, config, v1.EventTypeNormal, EventReasonReservationReleased, message, secondaryRoot.Name, &sns)
+	}
+	for _, reservedResources := range started {
+		logger.Info(fmt.Sprintf("Added ReservedResources of node %q to nodeGroup %s", reservedResources.NodeName, secondaryRoot.Name))
+		message := fmt.Sprintf("%s; reservation started for %s", nodeGroupChange, DescribeReservation(reservedResources))
+		RecordReservationEvent(recorder, config, v1.EventTypeWarning, EventReasonReservationStarted, message, secondaryRoot.Name, &sns)
+	}
 
-		if groupReserved.NodeGroup == "" || !isReservedResourceExpired(groupReserved, *config) {
-			setReservedToConfig(filteredDebt, secondaryRoot.Name, config, logger)
-			return sns, true, nil
+	activeReserved := getActiveReservedResourcesByGroup(secondaryRoot.Name, *config)
+	quota := filterUncontrolledResources(MergeTwoResourceList(groupQuota.Multiplied, activeReserved), config.Spec.ControlledResources)
+	if !reflect.DeepEqual(sns.Spec.ResourceQuotaSpec.Hard, quota) {
+		sns.Spec.ResourceQuotaSpec.Hard = patchResourcesToList(sns.Spec.ResourceQuotaSpec.Hard, quota)
+	}
+
+	setQuotaStatusToConfig(groupQuota, quota, config)
+	return sns, len(activeReserved) > 0, nil
+}
+
+// setQuotaStatusToConfig sets the calculated quota of a secondary root in the NodeQuotaConfig status.
+// It takes the calculation of the node group, the final quota of the secondary root and the NodeQuotaConfig to modify.
+// The reserved resources of the node group are taken from the config, so they must be set before calling it.
+func setQuotaStatusToConfig(groupQuota danav1alpha1.QuotaStatus, quota v1.ResourceList, config *danav1alpha1.NodeQuotaConfig) {
+	groupQuota.ReservedResources = getActiveReservedResourcesByGroup(groupQuota.SecondaryRoot, *config)
+	groupQuota.Quota = quota
+	groupQuota.LastSyncTime = metav1.Now()
+
+	for i, quotaStatus := range config.Status.Quotas {
+		if quotaStatus.RootNamespace == groupQuota.RootNamespace && quotaStatus.SecondaryRoot == groupQuota.SecondaryRoot {
+			config.Status.Quotas[i] = groupQuota
+			return
+		}
+	}
+	config.Status.Quotas = append(config.Status.Quotas, groupQuota)
+}
+
+// SetRootQuotaStatusToConfig sets the calculated quota of a root in the NodeQuotaConfig status.
+// The calculation of the root is the sum of the calculations of its secondary roots, which must already be set in the status.
+func SetRootQuotaStatusToConfig(rootSubnamespace danav1alpha1.SubnamespacesRoots, rootResources v1.ResourceList, config *danav1alpha1.NodeQuotaConfig) {
+	rootQuota := danav1alpha1.QuotaStatus{RootNamespace: rootSubnamespace.RootNamespace}
+	for _, secondaryRoot := range rootSubnamespace.SecondaryRoots {
+		quotaStatus := getQuotaStatus(*config, rootSubnamespace.RootNamespace, secondaryRoot.Name)
+		if quotaStatus == nil {
+			continue
 		}
-	} else {
-		// one or more nodes added to cluster
-		totalResources := MergeTwoResourceList(groupResources, groupReserved.Resources)
-		filteredTotalResources := filterUncontrolledResources(totalResources, config.Spec.ControlledResources)
-		if isGreaterThan(filteredTotalResources, filteredSNSResources) || isEqualTo(filteredTotalResources, filteredSNSResources) {
-			removeReservedFromConfig(secondaryRoot.Name, config)
+		rootQuota.Nodes += quotaStatus.Nodes
+		rootQuota.Allocatable = MergeTwoResourceList(rootQuota.Allocatable, quotaStatus.Allocatable)
+		rootQuota.SystemResourceClaim = MergeTwoResourceList(rootQuota.SystemResourceClaim, quotaStatus.SystemResourceClaim)
+		rootQuota.Multiplied = MergeTwoResourceList(rootQuota.Multiplied, quotaStatus.Multiplied)
+		rootQuota.ReservedResources = MergeTwoResourceList(rootQuota.ReservedResources, quotaStatus.ReservedResources)
+	}
+	rootQuota.Quota = filterUncontrolledResources(rootResources, config.Spec.ControlledResources)
+	rootQuota.LastSyncTime = metav1.Now()
+
+	for i, quotaStatus := range config.Status.Quotas {
+		if quotaStatus.RootNamespace == rootQuota.RootNamespace && quotaStatus.SecondaryRoot == "" {
+			config.Status.Quotas[i] = rootQuota
+			return
+		}
+	}
+	config.Status.Quotas = append(config.Status.Quotas, rootQuota)
+}
+
+// getQuotaStatus returns the calculated quota of a root or a secondary root from the NodeQuotaConfig status.
+// It returns nil if the quota was never calculated.
+func getQuotaStatus(config danav1alpha1.NodeQuotaConfig, rootNamespace, secondaryRoot string) *danav1alpha1.QuotaStatus {
+	for _, quotaStatus := range config.Status.Quotas {
+		if quotaStatus.RootNamespace == rootNamespace && quotaStatus.SecondaryRoot == secondaryRoot {
+			return &quotaStatus
 		}
 	}
+	return nil
+}
 
-	if !reflect.DeepEqual(sns.Spec.ResourceQuotaSpec.Hard, groupResources) {
-		sns.Spec.ResourceQuotaSpec.Hard = patchResourcesToList(sns.Spec.ResourceQuotaSpec.Hard, groupResources)
+// RootNamespaceOf returns the root of the node group in the NodeQuotaConfig, or an empty string if it isn't found.
+func RootNamespaceOf(config danav1alpha1.NodeQuotaConfig, nodeGroup string) string {
+	for _, root := range config.Spec.Roots {
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if secondaryRoot.Name == nodeGroup {
+				return root.RootNamespace
+			}
+		}
 	}
+	return ""
+}
 
-	return sns, false, err
+// DeleteStaleQuotaStatuses removes the calculated quotas of roots and secondary roots which were removed from the NodeQuotaConfig spec.
+func DeleteStaleQuotaStatuses(config *danav1alpha1.NodeQuotaConfig) {
+	var quotas []danav1alpha1.QuotaStatus
+	for _, quotaStatus := range config.Status.Quotas {
+		for _, root := range config.Spec.Roots {
+			if root.RootNamespace != quotaStatus.RootNamespace {
+				continue
+			}
+			if quotaStatus.SecondaryRoot == "" || slices.ContainsFunc(root.SecondaryRoots, func(group danav1alpha1.NodeGroup) bool {
+				return group.Name == quotaStatus.SecondaryRoot
+			}) {
+				quotas = append(quotas, quotaStatus)
+			}
+		}
+	}
+	config.Status.Quotas = quotas
 }
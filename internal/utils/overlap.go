@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// maxOverlapsInCondition is the number of overlapping nodes which are named in the NodesOverlapping condition.
+const maxOverlapsInCondition = 10
+
+// FindNodeOverlaps finds the nodes which are selected by more than one node group of the NodeQuotaConfig, or by
+// a node group of the NodeQuotaConfig and a node group of another NodeQuotaConfig.
+// It returns the overlapping nodes sorted by name.
+func FindNodeOverlaps(ctx context.Context, r client.Client, config danav1alpha1.NodeQuotaConfig) ([]danav1alpha1.NodeOverlap, error) {
+	nodeList := v1.NodeList{}
+	if err := r.List(ctx, &nodeList); err != nil {
+		return nil, fmt.Errorf("failed to list the nodes: %w", err)
+	}
+
+	others, err := listOtherConfigs(ctx, r, config)
+	if err != nil {
+		return nil, err
+	}
+	return findNodeOverlaps(nodeList.Items, config, others)
+}
+
+// listOtherConfigs lists the NodeQuotaConfigs of the cluster other than the given NodeQuotaConfig.
+func listOtherConfigs(ctx context.Context, r client.Client, config danav1alpha1.NodeQuotaConfig) ([]danav1alpha1.NodeQuotaConfig, error) {
+	configList := danav1alpha1.NodeQuotaConfigList{}
+	if err := r.List(ctx, &configList); err != nil {
+		return nil, fmt.Errorf("failed to list the NodeQuotaConfigs: %w", err)
+	}
+	return otherConfigs(config, configList.Items), nil
+}
+
+// otherConfigs returns the given NodeQuotaConfigs other than the given NodeQuotaConfig.
+func otherConfigs(config danav1alpha1.NodeQuotaConfig, configs []danav1alpha1.NodeQuotaConfig) []danav1alpha1.NodeQuotaConfig {
+	var others []danav1alpha1.NodeQuotaConfig
+	for _, other := range configs {
+		if other.Namespace != config.Namespace || other.Name != config.Name {
+			others = append(others, other)
+		}
+	}
+	return others
+}
+
+// nodeGroupSelector is a node group along with its parsed selector.
+type nodeGroupSelector struct {
+	name     string
+	priority int32
+	selector labels.Selector
+}
+
+// findNodeOverlaps finds the overlapping nodes out of the given nodes. When the overlap policy is Priority, every node
+// which is selected by more than one node group of the NodeQuotaConfig is assigned to one of them.
+// Node groups of other NodeQuotaConfigs with an invalid selector are skipped, since they select no nodes, and catch-all
+// node groups are skipped, since they only receive the nodes which no other node group selects.
+func findNodeOverlaps(nodes []v1.Node, config danav1alpha1.NodeQuotaConfig, others []danav1alpha1.NodeQuotaConfig) ([]danav1alpha1.NodeOverlap, error) {
+	var own []nodeGroupSelector
+	for _, root := range config.Spec.Roots {
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if secondaryRoot.CatchAll {
+				continue
+			}
+			selector, err := NodeGroupSelector(secondaryRoot)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the node selector of node group %s: %w", secondaryRoot.Name, err)
+			}
+			own = append(own, nodeGroupSelector{name: secondaryRoot.Name, priority: secondaryRoot.Priority, selector: selector})
+		}
+	}
+
+	var foreign []nodeGroupSelector
+	for _, other := range others {
+		for _, root := range other.Spec.Roots {
+			for _, secondaryRoot := range root.SecondaryRoots {
+				if secondaryRoot.CatchAll {
+					continue
+				}
+				if selector, err := NodeGroupSelector(secondaryRoot); err == nil {
+					foreign = append(foreign, nodeGroupSelector{name: fmt.Sprintf("%s/%s/%s", other.Namespace, other.Name, secondaryRoot.Name), selector: selector})
+				}
+			}
+		}
+	}
+
+	var overlaps []danav1alpha1.NodeOverlap
+	for _, node := range nodes {
+		nodeLabels := labels.Set(node.Labels)
+		overlap := danav1alpha1.NodeOverlap{NodeName: node.Name}
+		var assigned *nodeGroupSelector
+		for i, group := range own {
+			if group.selector.Matches(nodeLabels) {
+				overlap.NodeGroups = append(overlap.NodeGroups, group.name)
+				if assigned == nil || group.priority > assigned.priority {
+					assigned = &own[i]
+				}
+			}
+		}
+		if len(overlap.NodeGroups) == 0 {
+			continue
+		}
+		for _, group := range foreign {
+			if group.selector.Matches(nodeLabels) {
+				overlap.OtherConfigs = append(overlap.OtherConfigs, group.name)
+			}
+		}
+		if len(overlap.NodeGroups) < 2 && len(overlap.OtherConfigs) == 0 {
+			continue
+		}
+
+		if config.Spec.OverlapPolicy == danav1alpha1.OverlapPolicyPriority && len(overlap.NodeGroups) > 1 {
+			overlap.AssignedTo = assigned.name
+		}
+		overlaps = append(overlaps, overlap)
+	}
+	sort.Slice(overlaps, func(i, j int) bool { return overlaps[i].NodeName < overlaps[j].NodeName })
+	return overlaps, nil
+}
+
+// filterAssignedNodes removes the nodes which are assigned to another node group by the overlap policy.
+func filterAssignedNodes(nodes v1.NodeList, nodeGroup string, overlaps []danav1alpha1.NodeOverlap) v1.NodeList {
+	assignedTo := map[string]string{}
+	for _, overlap := range overlaps {
+		if overlap.AssignedTo != "" {
+			assignedTo[overlap.NodeName] = overlap.AssignedTo
+		}
+	}
+
+	filtered := v1.NodeList{}
+	for _, node := range nodes.Items {
+		if group, ok := assignedTo[node.Name]; ok && group != nodeGroup {
+			continue
+		}
+		filtered.Items = append(filtered.Items, node)
+	}
+	return filtered
+}
+
+// SetNodesOverlappingCondition sets the NodesOverlapping condition according to the overlapping nodes in the status.
+func SetNodesOverlappingCondition(config *danav1alpha1.NodeQuotaConfig) {
+	overlaps := config.Status.OverlappingNodes
+	if len(overlaps) == 0 {
+		setCondition(config, danav1alpha1.ConditionTypeNodesOverlapping, metav1.ConditionFalse, ReasonAsExpected, "no node is selected by more than one node group")
+		return
+	}
+
+	var descriptions []string
+	for i, overlap := range overlaps {
+		if i == maxOverlapsInCondition {
+			descriptions = append(descriptions, fmt.Sprintf("and %d more", len(overlaps)-maxOverlapsInCondition))
+			break
+		}
+		description := fmt.Sprintf("%s (%s)", overlap.NodeName, strings.Join(append(append([]string{}, overlap.NodeGroups...), overlap.OtherConfigs...), ", "))
+		if overlap.AssignedTo != "" {
+			description = fmt.Sprintf("%s counted in %s", description, overlap.AssignedTo)
+		}
+		descriptions = append(descriptions, description)
+	}
+	message := fmt.Sprintf("nodes are selected by more than one node group: %s", strings.Join(descriptions, ", "))
+	setCondition(config, danav1alpha1.ConditionTypeNodesOverlapping, metav1.ConditionTrue, ReasonNodesOverlapping, message)
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// ParsePauseAnnotations parses the annotations which pause the NodeQuotaConfig or some of its node groups.
+// It returns whether the whole NodeQuotaConfig is paused and the names of the paused node groups.
+func ParsePauseAnnotations(annotations map[string]string) (bool, []string, error) {
+	paused := false
+	if value, ok := annotations[danav1alpha1.PausedAnnotation]; ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, nil, fmt.Errorf("invalid %s annotation: %q must be true or false", danav1alpha1.PausedAnnotation, value)
+		}
+		paused = parsed
+	}
+	return paused, splitAnnotationList(annotations[danav1alpha1.PausedNodeGroupsAnnotation]), nil
+}
+
+// IsConfigPaused checks if the whole NodeQuotaConfig is paused. A malformed annotation doesn't pause the NodeQuotaConfig.
+func IsConfigPaused(config danav1alpha1.NodeQuotaConfig) bool {
+	paused, _, err := ParsePauseAnnotations(config.Annotations)
+	return err == nil && paused
+}
+
+// IsNodeGroupPaused checks if the writes of the quota of a node group are paused, either since the whole NodeQuotaConfig
+// is paused or since the node group is paused on its own.
+func IsNodeGroupPaused(config danav1alpha1.NodeQuotaConfig, nodeGroup string) bool {
+	if IsConfigPaused(config) {
+		return true
+	}
+	_, nodeGroups, _ := ParsePauseAnnotations(config.Annotations)
+	for _, paused := range nodeGroups {
+		if paused == nodeGroup {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRootPaused checks if the writes of the quota of a root are affected by a pause, since the whole NodeQuotaConfig
+// or one of its secondary roots is paused.
+func IsRootPaused(config danav1alpha1.NodeQuotaConfig, rootSubnamespace danav1alpha1.SubnamespacesRoots) bool {
+	for _, secondaryRoot := range rootSubnamespace.SecondaryRoots {
+		if IsNodeGroupPaused(config, secondaryRoot.Name) {
+			return true
+		}
+	}
+	return IsConfigPaused(config)
+}
+
+// PausedNodeGroups returns the names of the node groups of the NodeQuotaConfig whose writes are paused.
+func PausedNodeGroups(config danav1alpha1.NodeQuotaConfig) []string {
+	var paused []string
+	for _, root := range config.Spec.Roots {
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if IsNodeGroupPaused(config, secondaryRoot.Name) {
+				paused = append(paused, secondaryRoot.Name)
+			}
+		}
+	}
+	return paused
+}
+
+// pausedRootResources returns the quota which is written to a root when some of its secondary roots are paused.
+// Paused secondary roots keep their current quota, so it is counted in the root instead of their calculated quota.
+// The current quota of the secondary roots must already be set in the status.
+func pausedRootResources(rootSubnamespace danav1alpha1.SubnamespacesRoots, rootResources v1.ResourceList, config danav1alpha1.NodeQuotaConfig) v1.ResourceList {
+	resources := v1.ResourceList{}
+	for resourceName, quantity := range rootResources {
+		resources[resourceName] = quantity.DeepCopy()
+	}
+	for _, secondaryRoot := range rootSubnamespace.SecondaryRoots {
+		if !IsNodeGroupPaused(config, secondaryRoot.Name) {
+			continue
+		}
+		quotaStatus := getQuotaStatus(config, rootSubnamespace.RootNamespace, secondaryRoot.Name)
+		if quotaStatus == nil {
+			continue
+		}
+		for resourceName, quantity := range resources {
+			quantity.Sub(quotaStatus.Quota[resourceName])
+			quantity.Add(quotaStatus.Current[resourceName])
+			resources[resourceName] = quantity
+		}
+	}
+	return resources
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	danav1 "github.com/dana-team/hns/api/v1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// SetPlannedChangesToConfig sets the current quota and the planned change of a root and of its secondary roots
+// in the NodeQuotaConfig status, so the calculated quotas can be reviewed in DryRun mode, or while their writes are
+// paused, before they are written.
+// It takes the processed secondary roots of the root, and the children among them which would be trimmed by the
+// rebalance policy of their node group are set in the entry of their secondary root.
+// The calculated quotas must already be set in the status.
+func SetPlannedChangesToConfig(ctx context.Context, client client.Client, rootSubnamespace danav1alpha1.SubnamespacesRoots, processedSecondaryRoots []danav1.Subnamespace, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) error {
+	rootRQ, err := GetRootQuota(client, ctx, rootSubnamespace.RootNamespace)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("Error getting the %s resourceQuota", rootSubnamespace.RootNamespace))
+		return fmt.Errorf("failed to get root %s: %w", rootSubnamespace.RootNamespace, err)
+	}
+	setPlannedChange(config, rootSubnamespace.RootNamespace, "", rootRQ.Spec.Hard)
+
+	for _, secondaryRoot := range rootSubnamespace.SecondaryRoots {
+		sns := danav1.Subnamespace{}
+		if err := client.Get(ctx, types.NamespacedName{Namespace: rootSubnamespace.RootNamespace, Name: secondaryRoot.Name}, &sns); err != nil {
+			logger.Error(err, fmt.Sprintf("Error getting the subnamespace %s", secondaryRoot.Name))
+			return fmt.Errorf("failed to get secondary root %s: %w", secondaryRoot.Name, err)
+		}
+		setPlannedChange(config, rootSubnamespace.RootNamespace, secondaryRoot.Name, sns.Spec.ResourceQuotaSpec.Hard)
+	}
+
+	for _, child := range processedSecondaryRoots {
+		// only the rebalanced children of a secondary root have no quota status, and their namespace is the secondary root
+		if getQuotaStatus(*config, child.Namespace, child.Name) != nil {
+			continue
+		}
+		current := danav1.Subnamespace{}
+		if err := client.Get(ctx, types.NamespacedName{Namespace: child.Namespace, Name: child.Name}, &current); err != nil {
+			logger.Error(err, fmt.Sprintf("Error getting the subnamespace %s", child.Name))
+			return fmt.Errorf("failed to get child %s of secondary root %s: %w", child.Name, child.Namespace, err)
+		}
+		setPlannedRebalance(config, rootSubnamespace.RootNamespace, child.Namespace, child.Name, current.Spec.ResourceQuotaSpec.Hard, child.Spec.ResourceQuotaSpec.Hard)
+	}
+	return nil
+}
+
+// setPlannedRebalance sets the current and the trimmed quota of a child of a secondary root in the entry of the
+// secondary root in the NodeQuotaConfig status, along with the difference of every resource which would change.
+func setPlannedRebalance(config *danav1alpha1.NodeQuotaConfig, rootNamespace, secondaryRoot, child string, currentHard, newHard v1.ResourceList) {
+	for i, quotaStatus := range config.Status.Quotas {
+		if quotaStatus.RootNamespace != rootNamespace || quotaStatus.SecondaryRoot != secondaryRoot {
+			continue
+		}
+
+		quota := filterUncontrolledResources(newHard, config.Spec.ControlledResources)
+		current := filterResourcesByList(currentHard, quota)
+		config.Status.Quotas[i].PlannedRebalances = append(quotaStatus.PlannedRebalances, danav1alpha1.PlannedRebalance{
+			Subnamespace:  child,
+			Current:       current,
+			Quota:         quota,
+			PlannedChange: resourcesChange(current, quota),
+		})
+		return
+	}
+}
+
+// setPlannedChange sets the current quota of a root or a secondary root in its entry in the NodeQuotaConfig status,
+// along with the difference between the calculated quota and the current quota of every resource which would change.
+func setPlannedChange(config *danav1alpha1.NodeQuotaConfig, rootNamespace, secondaryRoot string, currentHard v1.ResourceList) {
+	for i, quotaStatus := range config.Status.Quotas {
+		if quotaStatus.RootNamespace != rootNamespace || quotaStatus.SecondaryRoot != secondaryRoot {
+			continue
+		}
+
+		current := filterResourcesByList(currentHard, quotaStatus.Quota)
+		config.Status.Quotas[i].Current = current
+		config.Status.Quotas[i].PlannedChange = resourcesChange(current, quotaStatus.Quota)
+		return
+	}
+}
+
+// resourcesChange returns the difference between the new and the current quantity of every resource which would change.
+func resourcesChange(current, newResources v1.ResourceList) v1.ResourceList {
+	change := v1.ResourceList{}
+	for resourceName, quantity := range newResources {
+		difference := quantity.DeepCopy()
+		difference.Sub(current[resourceName])
+		if !difference.IsZero() {
+			change[resourceName] = difference
+		}
+	}
+	return change
+}
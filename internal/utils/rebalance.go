@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	danav1 "github.com/dana-team/hns/api/v1"
+	"github.com/go-logr/logr"
+	"gopkg.in/inf.v0"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// RebalanceSecondaryRootChildren trims the quotas of the direct child subnamespaces of a secondary root when the
+// calculated quota of the secondary root is below the sum of their quotas, according to the rebalance policy of the
+// node group. It returns the children whose quota was trimmed, so they are updated before the secondary root.
+func RebalanceSecondaryRootChildren(ctx context.Context, r client.Client, sns *danav1.Subnamespace, nodeGroup danav1alpha1.NodeGroup, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) ([]danav1.Subnamespace, error) {
+	if nodeGroup.Rebalance == nil {
+		return nil, nil
+	}
+
+	children := danav1.SubnamespaceList{}
+	if err := r.List(ctx, &children, client.InNamespace(sns.Name)); err != nil {
+		logger.Error(err, fmt.Sprintf("Error listing the children of the subnamespace %s", sns.Name))
+		return nil, fmt.Errorf("failed to list the children of secondary root %s: %w", sns.Name, err)
+	}
+
+	newHard := filterUncontrolledResources(sns.Spec.ResourceQuotaSpec.Hard, config.Spec.ControlledResources)
+	rebalanced := rebalanceChildren(children, newHard, *nodeGroup.Rebalance)
+	for _, child := range rebalanced {
+		logger.Info(fmt.Sprintf("Rebalancing child %s of secondary root %s to %s", child.Name, sns.Name, FormatResourceList(filterUncontrolledResources(child.Spec.ResourceQuotaSpec.Hard, config.Spec.ControlledResources))))
+	}
+	return rebalanced, nil
+}
+
+// rebalanceChildren divides the shortfall of every resource, which is the sum of the quotas of the children above the
+// new quota of their parent, between the children according to the rebalance policy. A child is never trimmed below zero,
+// and what a child can't give up is divided between the rest of the children.
+// It returns copies of the children whose quota was trimmed, sorted by name.
+func rebalanceChildren(children danav1.SubnamespaceList, newHard v1.ResourceList, policy danav1alpha1.RebalancePolicy) []danav1.Subnamespace {
+	trimmed := map[string]*danav1.Subnamespace{}
+	for resourceName, target := range newHard {
+		hards := map[string]*inf.Dec{}
+		weights := map[string]*inf.Dec{}
+		allocated := resource.Quantity{}
+		scale := roundingPrecision(resourceName, target)
+		for _, child := range children.Items {
+			if quantity, ok := child.Spec.ResourceQuotaSpec.Hard[resourceName]; ok {
+				hards[child.Name] = quantity.AsDec()
+				weights[child.Name] = rebalanceWeight(child.Name, quantity, policy)
+				allocated.Add(quantity)
+				if precision := roundingPrecision(resourceName, quantity); precision > scale {
+					scale = precision
+				}
+			}
+		}
+		if allocated.Cmp(target) <= 0 {
+			continue
+		}
+
+		shortfall := allocated.DeepCopy()
+		shortfall.Sub(target)
+		for name, cut := range divideShortfall(shortfall.AsDec(), hards, weights, scale) {
+			child, ok := trimmed[name]
+			if !ok {
+				child = GetSubnamespaceFromList(name, children).DeepCopy()
+				trimmed[name] = child
+			}
+			quantity := child.Spec.ResourceQuotaSpec.Hard[resourceName]
+			remaining := new(inf.Dec).Sub(quantity.AsDec(), cut)
+			child.Spec.ResourceQuotaSpec.Hard[resourceName] = *resource.NewDecimalQuantity(*remaining, quantity.Format)
+		}
+	}
+
+	var rebalanced []danav1.Subnamespace
+	for _, child := range trimmed {
+		rebalanced = append(rebalanced, *child)
+	}
+	sort.Slice(rebalanced, func(i, j int) bool { return rebalanced[i].Name < rebalanced[j].Name })
+	return rebalanced
+}
+
+// rebalanceWeight returns the weight of a child in the shortfall of a resource, which is its current quota of the resource
+// when the strategy is Proportional, or its configured weight when the strategy is Weighted.
+func rebalanceWeight(name string, quantity resource.Quantity, policy danav1alpha1.RebalancePolicy) *inf.Dec {
+	if policy.Strategy != danav1alpha1.RebalanceStrategyWeighted {
+		return quantity.AsDec()
+	}
+	weight, ok := policy.Weights[name]
+	if !ok {
+		weight = 1
+	}
+	return inf.NewDec(int64(weight), 0)
+}
+
+// divideShortfall divides the shortfall between the children according to their weights, rounding every cut up to the
+// given number of decimal places. A cut is never larger than the quota of the child, and the part of the shortfall
+// which a child can't give up is divided again between the children which still have quota.
+// It returns the cut of every child which gives up quota.
+func divideShortfall(shortfall *inf.Dec, hards map[string]*inf.Dec, weights map[string]*inf.Dec, scale inf.Scale) map[string]*inf.Dec {
+	names := make([]string, 0, len(hards))
+	for name := range hards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zero := inf.NewDec(0, 0)
+	cuts := map[string]*inf.Dec{}
+	remaining := new(inf.Dec).Set(shortfall)
+	for remaining.Cmp(zero) > 0 {
+		totalWeight := inf.NewDec(0, 0)
+		var active []string
+		for _, name := range names {
+			left := new(inf.Dec).Sub(hards[name], cutOf(cuts, name))
+			if weights[name].Cmp(zero) > 0 && left.Cmp(zero) > 0 {
+				active = append(active, name)
+				totalWeight.Add(totalWeight, weights[name])
+			}
+		}
+		if len(active) == 0 {
+			break
+		}
+
+		toDivide := new(inf.Dec).Set(remaining)
+		for _, name := range active {
+			share := new(inf.Dec).Mul(toDivide, weights[name])
+			share.QuoRound(share, totalWeight, scale, inf.RoundCeil)
+			left := new(inf.Dec).Sub(hards[name], cutOf(cuts, name))
+			for _, limit := range []*inf.Dec{left, remaining} {
+				if share.Cmp(limit) > 0 {
+					share.Set(limit)
+				}
+			}
+			cuts[name] = new(inf.Dec).Add(cutOf(cuts, name), share)
+			remaining.Sub(remaining, share)
+		}
+	}
+
+	for name, cut := range cuts {
+		if cut.Cmp(zero) == 0 {
+			delete(cuts, name)
+		}
+	}
+	return cuts
+}
+
+// cutOf returns the cut of a child, or zero if the child doesn't give up quota yet.
+func cutOf(cuts map[string]*inf.Dec, name string) *inf.Dec {
+	if cut, ok := cuts[name]; ok {
+		return cut
+	}
+	return inf.NewDec(0, 0)
+}
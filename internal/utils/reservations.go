@@ -0,0 +1,410 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// maxReservationRequests is the number of manual reservation requests which are kept in the status.
+const maxReservationRequests = 20
+
+// getReservedResourcesByGroup retrieves the reservations of a specific node group from the NodeQuotaConfig.
+// It takes the node group name and the NodeQuotaConfig.
+// It returns every reservation (danav1alpha1.ReservedResources) of the node group, one per removed node.
+func getReservedResourcesByGroup(group string, config danav1alpha1.NodeQuotaConfig) []danav1alpha1.ReservedResources {
+	var reservations []danav1alpha1.ReservedResources
+	for _, reservedResources := range config.Status.ReservedResources {
+		if reservedResources.NodeGroup == group {
+			reservations = append(reservations, reservedResources)
+		}
+	}
+	return reservations
+}
+
+// getActiveReservedResourcesByGroup sums the reservations of a node group which did not expire yet.
+func getActiveReservedResourcesByGroup(group string, config danav1alpha1.NodeQuotaConfig) v1.ResourceList {
+	active := v1.ResourceList{}
+	for _, reservedResources := range getReservedResourcesByGroup(group, config) {
+		if !isReservedResourceExpired(reservedResources, config) {
+			active = MergeTwoResourceList(active, reservedResources.Resources)
+		}
+	}
+	return active
+}
+
+// OldestReservation returns when the oldest active reservation of a node group started, and whether the node group
+// has any active reservation.
+func OldestReservation(config danav1alpha1.NodeQuotaConfig, group string) (metav1.Time, bool) {
+	var oldest metav1.Time
+	found := false
+	for _, reservedResources := range getReservedResourcesByGroup(group, config) {
+		if isReservedResourceExpired(reservedResources, config) {
+			continue
+		}
+		if !found || reservedResources.Timestamp.Before(&oldest) {
+			oldest = reservedResources.Timestamp
+			found = true
+		}
+	}
+	return oldest, found
+}
+
+// DeleteExpiredReservedResources removes the expired reserved resources from the NodeQuotaConfig.
+// It takes the NodeQuotaConfig to modify and a logger for logging informational messages.
+// It returns the reserved resources which were removed.
+func DeleteExpiredReservedResources(config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) []danav1alpha1.ReservedResources {
+	var newReservedResources []danav1alpha1.ReservedResources
+	var expiredReservedResources []danav1alpha1.ReservedResources
+
+	for _, resources := range config.Status.ReservedResources {
+		if isReservedResourceExpired(resources, *config) {
+			logger.Info(fmt.Sprintf("Removed ReservedResources of node %q from nodeGroup %s", resources.NodeName, resources.NodeGroup))
+			expiredReservedResources = append(expiredReservedResources, resources)
+		} else {
+			newReservedResources = append(newReservedResources, resources)
+		}
+	}
+	config.Status.ReservedResources = newReservedResources
+	return expiredReservedResources
+}
+
+// NextReservationExpiry returns the time left until the first of the reservations in the NodeQuotaConfig expires.
+// The boolean is false if there are no reservations.
+func NextReservationExpiry(config danav1alpha1.NodeQuotaConfig) (time.Duration, bool) {
+	if len(config.Status.ReservedResources) == 0 {
+		return 0, false
+	}
+
+	next := time.Until(reservationExpirationTime(config.Status.ReservedResources[0], config))
+	for _, reservedResources := range config.Status.ReservedResources[1:] {
+		if untilExpiry := time.Until(reservationExpirationTime(reservedResources, config)); untilExpiry < next {
+			next = untilExpiry
+		}
+	}
+	return max(next, time.Minute), true
+}
+
+// isReservedResourceExpired checks if a reserved created more than X hours ago, defined by the user in the config CRD.
+// A reservation which was extended expires at its ExpirationTime instead.
+func isReservedResourceExpired(reservedResources danav1alpha1.ReservedResources, config danav1alpha1.NodeQuotaConfig) bool {
+	if reservedResources.ExpirationTime != nil {
+		return !time.Now().Before(reservedResources.ExpirationTime.Time)
+	}
+	return hoursPassedSinceDate(reservedResources.Timestamp) >= config.Spec.ReservedHoursToLive
+}
+
+// reservationExpirationTime returns the time in which a reservation expires.
+func reservationExpirationTime(reservedResources danav1alpha1.ReservedResources, config danav1alpha1.NodeQuotaConfig) time.Time {
+	if reservedResources.ExpirationTime != nil {
+		return reservedResources.ExpirationTime.Time
+	}
+	return reservedResources.Timestamp.Add(time.Duration(config.Spec.ReservedHoursToLive) * time.Hour)
+}
+
+// HasReservationRequests checks if the annotations of a NodeQuotaConfig request to release or extend reservations.
+func HasReservationRequests(annotations map[string]string) bool {
+	_, release := annotations[danav1alpha1.ReleaseReservationsAnnotation]
+	_, extend := annotations[danav1alpha1.ExtendReservationsAnnotation]
+	return release || extend
+}
+
+// ParseReservationRequests parses the requests to release or extend reservations from the annotations of a NodeQuotaConfig.
+// The user who asked for the requests is taken from the requester annotation, which is set by the webhook.
+// It returns an error if one of the annotations is malformed.
+func ParseReservationRequests(annotations map[string]string) ([]danav1alpha1.ReservationRequest, error) {
+	var requests []danav1alpha1.ReservationRequest
+	requester := annotations[danav1alpha1.ReservationRequesterAnnotation]
+
+	for _, target := range splitAnnotationList(annotations[danav1alpha1.ReleaseReservationsAnnotation]) {
+		nodeGroup, nodeName, err := parseReservationTarget(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", danav1alpha1.ReleaseReservationsAnnotation, err)
+		}
+		requests = append(requests, danav1alpha1.ReservationRequest{
+			Action:      danav1alpha1.ReservationActionRelease,
+			NodeGroup:   nodeGroup,
+			NodeName:    nodeName,
+			RequestedBy: requester,
+		})
+	}
+
+	for _, item := range splitAnnotationList(annotations[danav1alpha1.ExtendReservationsAnnotation]) {
+		target, value, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid %s annotation: %q must be in the form nodeGroup=duration", danav1alpha1.ExtendReservationsAnnotation, item)
+		}
+		nodeGroup, nodeName, err := parseReservationTarget(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", danav1alpha1.ExtendReservationsAnnotation, err)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", danav1alpha1.ExtendReservationsAnnotation, err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("invalid %s annotation: duration of %q must be greater than 0", danav1alpha1.ExtendReservationsAnnotation, target)
+		}
+		requests = append(requests, danav1alpha1.ReservationRequest{
+			Action:      danav1alpha1.ReservationActionExtend,
+			NodeGroup:   nodeGroup,
+			NodeName:    nodeName,
+			Duration:    &metav1.Duration{Duration: duration},
+			RequestedBy: requester,
+		})
+	}
+	return requests, nil
+}
+
+// splitAnnotationList splits a comma separated annotation value, ignoring empty items.
+func splitAnnotationList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseReservationTarget parses a nodeGroup or nodeGroup/nodeName target of a reservation request.
+func parseReservationTarget(target string) (string, string, error) {
+	nodeGroup, nodeName, _ := strings.Cut(strings.TrimSpace(target), "/")
+	if nodeGroup == "" {
+		return "", "", fmt.Errorf("%q must start with a node group name", target)
+	}
+	return nodeGroup, nodeName, nil
+}
+
+// ApplyReservationRequests releases or extends the reservations of the NodeQuotaConfig according to the given requests.
+// It is handled together with DeleteExpiredReservedResources, before the quotas are calculated, so released
+// reservations are not kept in the quota. Every request is recorded in the status with who asked for it and when,
+// and an event is recorded on the NodeQuotaConfig for every reservation it was applied to.
+func ApplyReservationRequests(config *danav1alpha1.NodeQuotaConfig, requests []danav1alpha1.ReservationRequest, recorder record.EventRecorder, logger logr.Logger) {
+	for _, request := range requests {
+		request.RequestTime = metav1.Now()
+		requester := request.RequestedBy
+		if requester == "" {
+			requester = "an unknown user"
+		}
+
+		for _, reservedResources := range getReservedResourcesByGroup(request.NodeGroup, *config) {
+			if request.NodeName != "" && request.NodeName != reservedResources.NodeName {
+				continue
+			}
+			request.Reservations++
+
+			switch request.Action {
+			case danav1alpha1.ReservationActionRelease:
+				releaseReservation(reservedResources, config)
+				logger.Info(fmt.Sprintf("Released ReservedResources of node %q from nodeGroup %s on request of %s", reservedResources.NodeName, request.NodeGroup, requester))
+				message := fmt.Sprintf("reservation of %s for node group %s released on request of %s", DescribeReservation(reservedResources), request.NodeGroup, requester)
+				RecordReservationEvent(recorder, config, v1.EventTypeNormal, EventReasonReservationReleased, message, request.NodeGroup)
+			case danav1alpha1.ReservationActionExtend:
+				expirationTime := metav1.NewTime(reservationExpirationTime(reservedResources, *config).Add(request.Duration.Duration))
+				extendReservation(reservedResources, expirationTime, config)
+				logger.Info(fmt.Sprintf("Extended ReservedResources of node %q from nodeGroup %s on request of %s", reservedResources.NodeName, request.NodeGroup, requester))
+				message := fmt.Sprintf("reservation of %s for node group %s extended by %s until %s on request of %s", DescribeReservation(reservedResources), request.NodeGroup, request.Duration.Duration, expirationTime.UTC().Format(time.RFC3339), requester)
+				RecordReservationEvent(recorder, config, v1.EventTypeNormal, EventReasonReservationUpdated, message, request.NodeGroup)
+			}
+		}
+		config.Status.ReservationRequests = append(config.Status.ReservationRequests, request)
+	}
+
+	if len(config.Status.ReservationRequests) > maxReservationRequests {
+		config.Status.ReservationRequests = config.Status.ReservationRequests[len(config.Status.ReservationRequests)-maxReservationRequests:]
+	}
+}
+
+// releaseReservation removes a reservation from the NodeQuotaConfig and its resources from the previous calculation of
+// the node group, so the released resources are not reserved again as missing resources in the next calculation.
+func releaseReservation(reservedResources danav1alpha1.ReservedResources, config *danav1alpha1.NodeQuotaConfig) {
+	removeReservedFromConfig(reservedResources, config)
+	for i, quotaStatus := range config.Status.Quotas {
+		if quotaStatus.SecondaryRoot == reservedResources.NodeGroup {
+			config.Status.Quotas[i].ReservedResources = positiveDifference(quotaStatus.ReservedResources, reservedResources.Resources)
+		}
+	}
+}
+
+// extendReservation sets the expiration time of a reservation in the NodeQuotaConfig.
+func extendReservation(reservedResources danav1alpha1.ReservedResources, expirationTime metav1.Time, config *danav1alpha1.NodeQuotaConfig) {
+	for i, existing := range config.Status.ReservedResources {
+		if isSameReservation(existing, reservedResources) {
+			config.Status.ReservedResources[i].ExpirationTime = &expirationTime
+		}
+	}
+}
+
+// updateGroupReservations updates the reservations of a node group according to its current calculation.
+// Reservations of nodes which came back to the node group are released. Resources which are missing compared
+// to the previous quota of the node group are reserved per removed node, each with its own timestamp,
+// so the resources of every node expire independently. Nodes which were excluded by the eligibility rules are
+// reserved with their own resources. The resources of a deleted node can't be read anymore,
+// so the rest of the missing resources are split evenly between the nodes which were deleted together.
+// Missing resources that can't be attributed to a removed node are reserved without a node name.
+// It returns the released and the started reservations.
+func updateGroupReservations(config *danav1alpha1.NodeQuotaConfig, groupQuota danav1alpha1.QuotaStatus, previousQuota v1.ResourceList, previousNodes []string) ([]danav1alpha1.ReservedResources, []danav1alpha1.ReservedResources) {
+	var released []danav1alpha1.ReservedResources
+	var reservedNodes []string
+	attributed := v1.ResourceList{}
+	unattributed := v1.ResourceList{}
+	for _, reservedResources := range getReservedResourcesByGroup(groupQuota.SecondaryRoot, *config) {
+		switch {
+		case reservedResources.NodeName == "":
+			unattributed = MergeTwoResourceList(unattributed, reservedResources.Resources)
+		case slices.Contains(groupQuota.NodeNames, reservedResources.NodeName):
+			released = append(released, reservedResources)
+			removeReservedFromConfig(reservedResources, config)
+		default:
+			reservedNodes = append(reservedNodes, reservedResources.NodeName)
+			attributed = MergeTwoResourceList(attributed, reservedResources.Resources)
+		}
+	}
+
+	covered := MergeTwoResourceList(groupQuota.Multiplied, attributed)
+	if len(unattributed) > 0 && (isGreaterThan(covered, previousQuota) || isEqualTo(covered, previousQuota)) {
+		// the node group grew back, so resources which are not tied to a node are not needed anymore
+		for _, reservedResources := range getReservedResourcesByGroup(groupQuota.SecondaryRoot, *config) {
+			if reservedResources.NodeName == "" {
+				released = append(released, reservedResources)
+				removeReservedFromConfig(reservedResources, config)
+			}
+		}
+		unattributed = v1.ResourceList{}
+	}
+
+	missing := positiveDifference(previousQuota, MergeTwoResourceList(covered, unattributed))
+	if len(missing) == 0 {
+		return released, nil
+	}
+
+	var removedNodes []string
+	for _, nodeName := range previousNodes {
+		if !slices.Contains(groupQuota.NodeNames, nodeName) && !slices.Contains(reservedNodes, nodeName) {
+			removedNodes = append(removedNodes, nodeName)
+		}
+	}
+
+	var started []danav1alpha1.ReservedResources
+	if len(removedNodes) == 0 {
+		if !isGreaterThan(previousQuota, MergeTwoResourceList(covered, unattributed)) {
+			return released, nil
+		}
+		started = append(started, danav1alpha1.ReservedResources{
+			NodeGroup: groupQuota.SecondaryRoot,
+			Resources: missing,
+			Timestamp: metav1.Now(),
+		})
+		config.Status.ReservedResources = append(config.Status.ReservedResources, started...)
+		return released, started
+	}
+
+	// nodes which were excluded by the eligibility rules still exist, so their exact resources are known
+	var unknownNodes []string
+	for _, nodeName := range removedNodes {
+		index := slices.IndexFunc(groupQuota.ExcludedNodes, func(excludedNode danav1alpha1.ExcludedNode) bool {
+			return excludedNode.Name == nodeName
+		})
+		if index == -1 {
+			unknownNodes = append(unknownNodes, nodeName)
+			continue
+		}
+		contribution := filterResourcesByList(groupQuota.ExcludedNodes[index].Resources, missing)
+		for resourceName, quantity := range contribution {
+			if quantity.Cmp(missing[resourceName]) > 0 {
+				contribution[resourceName] = missing[resourceName]
+			}
+		}
+		if contribution = positiveDifference(contribution, v1.ResourceList{}); len(contribution) == 0 {
+			continue
+		}
+		missing = positiveDifference(missing, contribution)
+		started = append(started, danav1alpha1.ReservedResources{
+			NodeGroup: groupQuota.SecondaryRoot,
+			NodeName:  nodeName,
+			Resources: contribution,
+			Timestamp: metav1.Now(),
+		})
+	}
+
+	if len(missing) > 0 && len(unknownNodes) > 0 {
+		for i, contribution := range splitResourceList(missing, len(unknownNodes)) {
+			started = append(started, danav1alpha1.ReservedResources{
+				NodeGroup: groupQuota.SecondaryRoot,
+				NodeName:  unknownNodes[i],
+				Resources: contribution,
+				Timestamp: metav1.Now(),
+			})
+		}
+	}
+	config.Status.ReservedResources = append(config.Status.ReservedResources, started...)
+	return released, started
+}
+
+// removeReservedFromConfig removes a single reservation of a node group from the NodeQuotaConfig.
+// It takes the reservation to remove and the NodeQuotaConfig to modify.
+func removeReservedFromConfig(reservedResources danav1alpha1.ReservedResources, config *danav1alpha1.NodeQuotaConfig) {
+	index := slices.IndexFunc(config.Status.ReservedResources, func(existing danav1alpha1.ReservedResources) bool {
+		return isSameReservation(existing, reservedResources)
+	})
+	if index == -1 {
+		return
+	}
+	config.Status.ReservedResources = slices.Delete(config.Status.ReservedResources, index, index+1)
+}
+
+// isSameReservation checks if both reservations belong to the same node group and node and were created at the same time.
+func isSameReservation(reservedResources danav1alpha1.ReservedResources, reservedResources2 danav1alpha1.ReservedResources) bool {
+	return reservedResources.NodeGroup == reservedResources2.NodeGroup && reservedResources.NodeName == reservedResources2.NodeName &&
+		reservedResources.Timestamp.Equal(&reservedResources2.Timestamp)
+}
+
+// positiveDifference subtracts the quantities of resourcesList2 from resourcesList.
+// It returns only the resources for which the result is greater than zero.
+func positiveDifference(resourcesList v1.ResourceList, resourcesList2 v1.ResourceList) v1.ResourceList {
+	result := v1.ResourceList{}
+	for resourceName, quantity := range subtractTwoResourceList(resourcesList, filterResourcesByList(resourcesList2, resourcesList)) {
+		if quantity.Sign() > 0 {
+			result[resourceName] = quantity
+		}
+	}
+	return result
+}
+
+// splitResourceList splits the quantities of a resource list into the given number of equal parts.
+// Whole quantities are split into whole units and the remainder is added to the last part.
+func splitResourceList(resourcesList v1.ResourceList, parts int) []v1.ResourceList {
+	result := make([]v1.ResourceList, parts)
+	for i := range result {
+		result[i] = v1.ResourceList{}
+	}
+
+	for resourceName, quantity := range resourcesList {
+		scale := resource.Scale(0)
+		value := quantity.Value()
+		if quantity.MilliValue()%1000 != 0 {
+			scale = resource.Milli
+			value = quantity.MilliValue()
+		}
+		part := value / int64(parts)
+		for i := range result {
+			if i == parts-1 {
+				part = value - part*int64(parts-1)
+			}
+			partQuantity := resource.NewScaledQuantity(part, scale)
+			partQuantity.Format = quantity.Format
+			result[i][resourceName] = *partQuantity
+		}
+	}
+	return result
+}
@@ -2,13 +2,15 @@ package utils
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/go-logr/logr"
+	"gopkg.in/inf.v0"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/utils/strings/slices"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
 )
 
 // filterUncontrolledResources filters the given resources list based on the controlled resources.
@@ -23,6 +25,18 @@ func filterUncontrolledResources(resourcesList v1.ResourceList, controlledResour
 	return filteredList
 }
 
+// filterResourcesByList filters the given resources list based on the resources of another list.
+// It returns a new resource list that contains only the resources which appear in the second list.
+func filterResourcesByList(resourcesList v1.ResourceList, resourcesToKeep v1.ResourceList) v1.ResourceList {
+	filteredList := v1.ResourceList{}
+	for resourceName := range resourcesToKeep {
+		if quantity, ok := resourcesList[resourceName]; ok {
+			filteredList[resourceName] = quantity
+		}
+	}
+	return filteredList
+}
+
 // isGreaterThan checks if the quantities in resourcesList are greater than or equal to the corresponding quantities in resourcesList2.
 func isGreaterThan(resourcesList v1.ResourceList, resourcesList2 v1.ResourceList) bool {
 	for resourceName, resourceQuantity := range resourcesList {
@@ -123,28 +137,67 @@ func patchResourcesToList(resourcesList v1.ResourceList, resourcesToPatch v1.Res
 }
 
 // multiplyResourceList multiplies the values of resources in the given resource list by the corresponding factors.
+// The reserved resources are subtracted before the multiplication, nil is passed to multiply the resources as they are.
+// The multiplication is exact, and the result is rounded according to the rounding mode of the resource.
 // It returns a new resource list with the multiplied values.
-func multiplyResourceList(resources v1.ResourceList, factor map[string]string, reservedResources map[string]resource.Quantity, logger logr.Logger) v1.ResourceList {
+func multiplyResourceList(resources v1.ResourceList, factor map[string]string, roundingModes map[string]danav1alpha1.RoundingMode, reservedResources map[string]resource.Quantity, logger logr.Logger) v1.ResourceList {
 	result := subtractResources(resources, reservedResources, logger)
-	for name, value := range resources {
+	for name, value := range result {
 		if factor[name.String()] == "" {
 			result[name] = value
 			continue
 		}
-		newValue := new(resource.Quantity)
-		newValue.Set(value.Value())
-
-		// Multiply the value by the factor
-		factorFloat, _ := strconv.ParseFloat(factor[name.String()], 64)
-		newValueInt64 := float64(newValue.Value()) * factorFloat
-		newValue.Set(int64(newValueInt64))
 
-		result[name] = *newValue
+		multiplier, err := ParseMultiplier(factor[name.String()])
+		if err != nil {
+			logger.Info(fmt.Sprintf("ignoring the multiplier of resource %s: %v", name, err.Error()))
+			result[name] = value
+			continue
+		}
+		result[name] = multiplyQuantity(value, multiplier, roundingPrecision(name, value), roundingModes[name.String()])
 	}
 
 	return result
 }
 
+// ParseMultiplier parses a resource multiplier, which must be a decimal number such as "2" or "1.5".
+func ParseMultiplier(value string) (*inf.Dec, error) {
+	multiplier, ok := new(inf.Dec).SetString(value)
+	if !ok {
+		return nil, fmt.Errorf("multiplier %q is not a valid decimal number", value)
+	}
+	return multiplier, nil
+}
+
+// multiplyQuantity multiplies the quantity by the multiplier without losing precision,
+// and rounds the result to the given number of decimal places according to the rounding mode.
+func multiplyQuantity(quantity resource.Quantity, multiplier *inf.Dec, scale inf.Scale, roundingMode danav1alpha1.RoundingMode) resource.Quantity {
+	product := new(inf.Dec).Mul(quantity.AsDec(), multiplier)
+	product.Round(product, scale, rounder(roundingMode))
+	return *resource.NewDecimalQuantity(*product, quantity.Format)
+}
+
+// roundingPrecision returns the number of decimal places a multiplied quantity is rounded to.
+// CPU and quantities which already have a fractional part keep milli-units, other quantities are whole units.
+func roundingPrecision(name v1.ResourceName, quantity resource.Quantity) inf.Scale {
+	if name == v1.ResourceCPU || new(inf.Dec).Round(quantity.AsDec(), 0, inf.RoundExact) == nil {
+		return 3
+	}
+	return 0
+}
+
+// rounder returns the rounder of the given rounding mode, rounding down by default.
+func rounder(roundingMode danav1alpha1.RoundingMode) inf.Rounder {
+	switch roundingMode {
+	case danav1alpha1.RoundingModeCeil:
+		return inf.RoundCeil
+	case danav1alpha1.RoundingModeNearest:
+		return inf.RoundHalfUp
+	default:
+		return inf.RoundFloor
+	}
+}
+
 func subtractResources(resources v1.ResourceList, reservedResources map[string]resource.Quantity, logger logr.Logger) v1.ResourceList {
 	result := resources.DeepCopy()
 
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	danav1 "github.com/dana-team/hns/api/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// NodeSetHash lists the nodes of every node group of the NodeQuotaConfig and hashes what the quotas are calculated from:
+// the names of the nodes, whether they are eligible and their allocatable resources.
+// It returns the hash, which changes whenever a recalculation may change the quotas.
+func NodeSetHash(ctx context.Context, r client.Client, config danav1alpha1.NodeQuotaConfig) (string, error) {
+	var entries []string
+	for _, root := range config.Spec.Roots {
+		for _, secondaryRoot := range root.SecondaryRoots {
+			labelSelector, err := NodeGroupSelector(secondaryRoot)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse the node selector of node group %s: %w", secondaryRoot.Name, err)
+			}
+			nodeList := v1.NodeList{}
+			if err := r.List(ctx, &nodeList, &client.ListOptions{LabelSelector: labelSelector}); err != nil {
+				return "", fmt.Errorf("failed to list the nodes of node group %s: %w", secondaryRoot.Name, err)
+			}
+
+			for _, node := range nodeList.Items {
+				entries = append(entries, fmt.Sprintf("%s/%s/%s/%q/%s", root.RootNamespace, secondaryRoot.Name, node.Name,
+					excludedNodeReason(node, secondaryRoot.Eligibility), FormatResourceList(node.Status.Allocatable)))
+			}
+		}
+	}
+	sort.Strings(entries)
+
+	hash := fnv.New64a()
+	for _, entry := range entries {
+		_, _ = hash.Write([]byte(entry))
+		_, _ = hash.Write([]byte{0})
+	}
+	return fmt.Sprintf("%016x", hash.Sum64()), nil
+}
+
+// SettleNodeSet checks if the nodes of the node groups are stable for the settle window of the NodeQuotaConfig.
+// When the nodes changed since the quotas were last calculated, the recalculation is pending until the nodes don't
+// change for the settle window, and every further change restarts the window. A change of the spec is recalculated
+// right away, since it was asked for explicitly.
+// It returns how long to wait until the recalculation, and whether the recalculation is pending.
+func SettleNodeSet(config *danav1alpha1.NodeQuotaConfig, nodeSetHash string) (time.Duration, bool) {
+	if config.Spec.SettleWindow == nil || config.Status.NodeSetHash == "" || config.Status.NodeSetHash == nodeSetHash ||
+		config.Status.CalculatedGeneration != config.Generation {
+		return 0, false
+	}
+
+	now := time.Now()
+	pending := config.Status.PendingRecalculation
+	if pending == nil || pending.NodeSetHash != nodeSetHash {
+		pending = &danav1alpha1.PendingRecalculation{
+			NodeSetHash:      nodeSetHash,
+			Since:            metav1.NewTime(now),
+			RecalculateAfter: metav1.NewTime(now.Add(config.Spec.SettleWindow.Duration)),
+		}
+		config.Status.PendingRecalculation = pending
+	}
+
+	untilRecalculation := pending.RecalculateAfter.Sub(now)
+	if untilRecalculation <= 0 {
+		return 0, false
+	}
+	message := fmt.Sprintf("the nodes of the node groups changed, the quotas are recalculated at %s if the nodes don't change again",
+		pending.RecalculateAfter.UTC().Format(time.RFC3339))
+	setCondition(config, danav1alpha1.ConditionTypeRecalculationPending, metav1.ConditionTrue, ReasonNodesSettling, message)
+	return untilRecalculation, true
+}
+
+// SetNodeSetCalculated records the hash of the nodes and the generation the quotas were calculated from, and clears the
+// pending recalculation.
+func SetNodeSetCalculated(config *danav1alpha1.NodeQuotaConfig, nodeSetHash string) {
+	config.Status.NodeSetHash = nodeSetHash
+	config.Status.CalculatedGeneration = config.Generation
+	config.Status.PendingRecalculation = nil
+	setCondition(config, danav1alpha1.ConditionTypeRecalculationPending, metav1.ConditionFalse, ReasonAsExpected, "the quotas are calculated from the current nodes")
+}
+
+// HeldQuotas returns the quotas of the root and its secondary roots from their last calculation, for writing them while
+// the recalculation from the nodes is pending. The reservations of every secondary root are replaced with its active
+// reservations, so released, extended and expired reservations are applied without recalculating the nodes.
+// The quotas are set in the status, and the secondary roots are returned as Subnamespaces which hold the quotas to write.
+// It returns false if a secondary root was never calculated, in which case nothing is written until the recalculation.
+func HeldQuotas(config *danav1alpha1.NodeQuotaConfig, rootSubnamespace danav1alpha1.SubnamespacesRoots) (v1.ResourceList, []danav1.Subnamespace, bool) {
+	rootResources := v1.ResourceList{}
+	var secondaryRoots []danav1.Subnamespace
+	for _, secondaryRoot := range rootSubnamespace.SecondaryRoots {
+		groupQuota := getQuotaStatus(*config, rootSubnamespace.RootNamespace, secondaryRoot.Name)
+		if groupQuota == nil {
+			return nil, nil, false
+		}
+
+		quota := v1.ResourceList{}
+		for resourceName, quantity := range MergeTwoResourceList(groupQuota.Quota, getActiveReservedResourcesByGroup(secondaryRoot.Name, *config)) {
+			if reserved, ok := groupQuota.ReservedResources[resourceName]; ok {
+				quantity.Sub(reserved)
+			}
+			quota[resourceName] = quantity
+		}
+		quota = filterUncontrolledResources(quota, config.Spec.ControlledResources)
+		setQuotaStatusToConfig(*groupQuota, quota, config)
+
+		sns := danav1.Subnamespace{ObjectMeta: metav1.ObjectMeta{Namespace: rootSubnamespace.RootNamespace, Name: secondaryRoot.Name}}
+		sns.Spec.ResourceQuotaSpec.Hard = quota
+		secondaryRoots = append(secondaryRoots, sns)
+		rootResources = MergeTwoResourceList(rootResources, quota)
+	}
+	SetRootQuotaStatusToConfig(rootSubnamespace, rootResources, config)
+	return rootResources, secondaryRoots, true
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	danav1 "github.com/dana-team/hns/api/v1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// ProtectSecondaryRootShrink checks the calculated quota of a secondary root against the sum of the quotas allocated
+// to its child subnamespaces before it is written. Resources which would be shrunk below the allocated sum are
+// handled according to the shrink policy of the NodeQuotaConfig: the shrink is refused, clamped to the allocated sum,
+// or written anyway. The quotas of children which are rebalanced are taken from the given rebalanced children.
+// The quota of the secondary root in the status is updated to the quota which will be written.
+// It returns a description of every resource whose shrink was refused or clamped.
+func ProtectSecondaryRootShrink(ctx context.Context, r client.Client, recorder record.EventRecorder, sns *danav1.Subnamespace, rebalanced []danav1.Subnamespace, config *danav1alpha1.NodeQuotaConfig, logger logr.Logger) ([]string, error) {
+	current := danav1.Subnamespace{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: sns.Namespace, Name: sns.Name}, &current); err != nil {
+		logger.Error(err, fmt.Sprintf("Error getting the subnamespace %s", sns.Name))
+		return nil, fmt.Errorf("failed to get secondary root %s: %w", sns.Name, err)
+	}
+
+	children := danav1.SubnamespaceList{}
+	if err := r.List(ctx, &children, client.InNamespace(sns.Name)); err != nil {
+		logger.Error(err, fmt.Sprintf("Error listing the children of the subnamespace %s", sns.Name))
+		return nil, fmt.Errorf("failed to list the children of secondary root %s: %w", sns.Name, err)
+	}
+	allocated := v1.ResourceList{}
+	for _, child := range children.Items {
+		if rebalancedChild := GetSubnamespaceFromList(child.Name, danav1.SubnamespaceList{Items: rebalanced}); rebalancedChild != nil {
+			child = *rebalancedChild
+		}
+		allocated = MergeTwoResourceList(allocated, child.Spec.ResourceQuotaSpec.Hard)
+	}
+	allocated = filterUncontrolledResources(allocated, config.Spec.ControlledResources)
+
+	protected := protectShrink(sns.Spec.ResourceQuotaSpec.Hard, current.Spec.ResourceQuotaSpec.Hard, allocated, config.Spec.ShrinkPolicy)
+	if len(protected) > 0 {
+		message := fmt.Sprintf("quota of secondary root %s is below what its children were allocated: %s", sns.Name, strings.Join(protected, ", "))
+		switch config.Spec.ShrinkPolicy {
+		case danav1alpha1.ShrinkPolicyProceed:
+			RecordEvent(recorder, v1.EventTypeWarning, EventReasonQuotaBelowAllocated, message, config, sns)
+			protected = nil
+		case danav1alpha1.ShrinkPolicyRefuse:
+			RecordEvent(recorder, v1.EventTypeWarning, EventReasonShrinkRefused, message, config, sns)
+		default:
+			RecordEvent(recorder, v1.EventTypeWarning, EventReasonShrinkClamped, message, config, sns)
+		}
+		logger.Info(message)
+	}
+
+	for i, quotaStatus := range config.Status.Quotas {
+		if quotaStatus.RootNamespace == sns.Namespace && quotaStatus.SecondaryRoot == sns.Name {
+			config.Status.Quotas[i].ChildrenAllocated = allocated
+			config.Status.Quotas[i].Quota = filterUncontrolledResources(sns.Spec.ResourceQuotaSpec.Hard, config.Spec.ControlledResources)
+		}
+	}
+
+	for i, description := range protected {
+		protected[i] = fmt.Sprintf("secondary root %s: %s", sns.Name, description)
+	}
+	return protected, nil
+}
+
+// protectShrink finds the resources of the new quota which are lower than both the current quota and the allocated sum.
+// Unless the shrink policy is Proceed, it changes the new quota of these resources to the current quota when the policy
+// is Refuse, or to the allocated sum when the policy is Clamp, but never above the current quota.
+// It returns a description of every such resource, sorted by the resource name.
+func protectShrink(newHard, currentHard, allocated v1.ResourceList, policy danav1alpha1.ShrinkPolicy) []string {
+	var protected []string
+	for resourceName, quantity := range filterResourcesByList(newHard, allocated) {
+		currentQuantity, ok := currentHard[resourceName]
+		allocatedQuantity := allocated[resourceName]
+		if !ok || quantity.Cmp(currentQuantity) >= 0 || quantity.Cmp(allocatedQuantity) >= 0 {
+			continue
+		}
+
+		kept := currentQuantity
+		switch policy {
+		case danav1alpha1.ShrinkPolicyProceed:
+			kept = quantity
+		case danav1alpha1.ShrinkPolicyRefuse:
+		default:
+			if allocatedQuantity.Cmp(currentQuantity) < 0 {
+				kept = allocatedQuantity
+			}
+		}
+		newHard[resourceName] = kept
+		protected = append(protected, fmt.Sprintf("%s calculated as %s, allocated %s, set to %s", resourceName, quantity.String(), allocatedQuantity.String(), kept.String()))
+	}
+	sort.Strings(protected)
+	return protected
+}
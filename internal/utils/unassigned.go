@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+)
+
+// FindUnassignedNodes finds the nodes which are not selected by any node group of the NodeQuotaConfig.
+// When the NodeQuotaConfig has a catch-all node group, every node is selected by a node group.
+// It returns the number, names and the sum of the allocatable controlled resources of the unassigned nodes.
+func FindUnassignedNodes(ctx context.Context, r client.Client, config danav1alpha1.NodeQuotaConfig) (*danav1alpha1.UnassignedNodes, error) {
+	nodeList := v1.NodeList{}
+	if err := r.List(ctx, &nodeList); err != nil {
+		return nil, fmt.Errorf("failed to list the nodes: %w", err)
+	}
+	return findUnassignedNodes(nodeList, config), nil
+}
+
+// findUnassignedNodes finds the unassigned nodes out of the given nodes.
+func findUnassignedNodes(nodes v1.NodeList, config danav1alpha1.NodeQuotaConfig) *danav1alpha1.UnassignedNodes {
+	unassigned := &danav1alpha1.UnassignedNodes{Allocatable: v1.ResourceList{}}
+	if hasCatchAllNodeGroup(config) {
+		return unassigned
+	}
+
+	for _, node := range nodes.Items {
+		if !selectedByNodeGroups(config, node.Labels) {
+			unassigned.Nodes++
+			unassigned.NodeNames = append(unassigned.NodeNames, node.Name)
+			unassigned.Allocatable = MergeTwoResourceList(unassigned.Allocatable, filterUncontrolledResources(node.Status.Allocatable, config.Spec.ControlledResources))
+		}
+	}
+	sort.Strings(unassigned.NodeNames)
+	return unassigned
+}
+
+// filterLeftoverNodes keeps only the nodes which are not selected by any node group of the NodeQuotaConfig other than
+// the catch-all node group, nor by a node group of the other NodeQuotaConfigs, which are the nodes the catch-all node
+// group receives. This way the capacity of a node is never counted both by a catch-all node group and by a node group
+// of another NodeQuotaConfig.
+func filterLeftoverNodes(nodes v1.NodeList, config danav1alpha1.NodeQuotaConfig, others []danav1alpha1.NodeQuotaConfig) v1.NodeList {
+	leftover := v1.NodeList{}
+	for _, node := range nodes.Items {
+		if !selectedByNodeGroups(config, node.Labels) && !selectedByOtherConfigs(others, node.Labels) {
+			leftover.Items = append(leftover.Items, node)
+		}
+	}
+	return leftover
+}
+
+// selectedByOtherConfigs checks if a node with the given labels is selected by one of the node groups of the other
+// NodeQuotaConfigs other than their catch-all node groups. NodeQuotaConfigs in DryRun mode are skipped, since they
+// never write the quotas and so don't take the capacity of any node.
+func selectedByOtherConfigs(others []danav1alpha1.NodeQuotaConfig, nodeLabels map[string]string) bool {
+	for _, other := range others {
+		if other.Spec.Mode != danav1alpha1.ConfigModeDryRun && selectedByNodeGroups(other, nodeLabels) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedByNodeGroups checks if a node with the given labels is selected by one of the node groups of the
+// NodeQuotaConfig other than the catch-all node group. Node groups with an invalid selector select no nodes.
+func selectedByNodeGroups(config danav1alpha1.NodeQuotaConfig, nodeLabels map[string]string) bool {
+	for _, root := range config.Spec.Roots {
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if secondaryRoot.CatchAll {
+				continue
+			}
+			selector, err := NodeGroupSelector(secondaryRoot)
+			if err == nil && selector.Matches(labels.Set(nodeLabels)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasCatchAllNodeGroup checks if one of the node groups of the NodeQuotaConfig is a catch-all node group.
+func hasCatchAllNodeGroup(config danav1alpha1.NodeQuotaConfig) bool {
+	for _, root := range config.Spec.Roots {
+		for _, secondaryRoot := range root.SecondaryRoots {
+			if secondaryRoot.CatchAll {
+				return true
+			}
+		}
+	}
+	return false
+}
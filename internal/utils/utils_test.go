@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -9,9 +13,19 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 const resourceName = "cpu"
@@ -233,7 +247,7 @@ func TestMultiplyResourceList(t *testing.T) {
 	}
 	logger := logr.Discard()
 
-	result := multiplyResourceList(resources, factor, reservedResources, logger)
+	result := multiplyResourceList(resources, factor, nil, reservedResources, logger)
 	assert.True(t, result.Cpu().Equal(*expectedResult.Cpu()))
 }
 
@@ -343,3 +357,1172 @@ func TestSubtractResources(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeGroupSelector(t *testing.T) {
+	nodeGroup := danav1alpha1.NodeGroup{
+		LabelSelector: map[string]string{"node-role": "worker"},
+		NodeSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "gpu", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"a100"}},
+				{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+			},
+		},
+	}
+
+	selector, err := NodeGroupSelector(nodeGroup)
+	assert.NoError(t, err)
+	assert.True(t, selector.Matches(labels.Set{"node-role": "worker", "gpu": "h100", "zone": "a"}))
+	assert.False(t, selector.Matches(labels.Set{"node-role": "worker", "gpu": "a100", "zone": "a"}))
+	assert.False(t, selector.Matches(labels.Set{"node-role": "worker", "zone": "c"}))
+	assert.False(t, selector.Matches(labels.Set{"zone": "b"}))
+
+	legacySelector, err := NodeGroupSelector(danav1alpha1.NodeGroup{LabelSelector: map[string]string{"app": "gpu"}})
+	assert.NoError(t, err)
+	assert.True(t, legacySelector.Matches(labels.Set{"app": "gpu"}))
+	assert.False(t, legacySelector.Matches(labels.Set{"app": "cpu"}))
+}
+
+func TestCalculateNodeGroupQuota(t *testing.T) {
+	config := danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{
+			ControlledResources: []string{"cpu", "memory"},
+			Roots: []danav1alpha1.SubnamespacesRoots{
+				{
+					RootNamespace: "cluster-root",
+					SecondaryRoots: []danav1alpha1.NodeGroup{
+						{
+							Name:                        "gpu",
+							ResourceMultiplier:          map[string]string{"cpu": "2"},
+							SystemResourceClaim:         map[string]resource.Quantity{"cpu": resource.MustParse("1")},
+							SubtractSystemResourceClaim: true,
+						},
+					},
+				},
+			},
+		},
+	}
+	node := v1.Node{
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("4"),
+				v1.ResourceMemory: resource.MustParse("8Gi"),
+				v1.ResourcePods:   resource.MustParse("110"),
+			},
+		},
+	}
+	nodes := v1.NodeList{Items: []v1.Node{node, node}}
+
+	result := calculateNodeGroupQuota(nodes, config, "gpu", logr.Discard())
+
+	assert.Equal(t, "gpu", result.SecondaryRoot)
+	assert.Equal(t, 2, result.Nodes)
+	assert.True(t, resource.MustParse("8").Equal(result.Allocatable[v1.ResourceCPU]))
+	assert.True(t, resource.MustParse("16Gi").Equal(result.Allocatable[v1.ResourceMemory]))
+	assert.True(t, resource.MustParse("2").Equal(result.SystemResourceClaim[v1.ResourceCPU]))
+	assert.True(t, resource.MustParse("12").Equal(result.Multiplied[v1.ResourceCPU]))
+	assert.True(t, resource.MustParse("16Gi").Equal(result.Multiplied[v1.ResourceMemory]))
+	assert.NotContains(t, result.Allocatable, v1.ResourcePods)
+
+	// by default the claim is only shown in the status, and isn't subtracted from the quota
+	config.Spec.Roots[0].SecondaryRoots[0].SubtractSystemResourceClaim = false
+	result = calculateNodeGroupQuota(nodes, config, "gpu", logr.Discard())
+	assert.True(t, resource.MustParse("2").Equal(result.SystemResourceClaim[v1.ResourceCPU]))
+	assert.True(t, resource.MustParse("16").Equal(result.Multiplied[v1.ResourceCPU]))
+}
+
+func TestSetConditions(t *testing.T) {
+	config := &danav1alpha1.NodeQuotaConfig{}
+
+	SetFailedConditions(config, ReasonUpdateFailed, []string{"failed to update root cluster-root: conflict"})
+	assert.True(t, meta.IsStatusConditionFalse(config.Status.Conditions, danav1alpha1.ConditionTypeReady))
+	assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, danav1alpha1.ConditionTypeDegraded))
+	synced := meta.FindStatusCondition(config.Status.Conditions, danav1alpha1.ConditionTypeSynced)
+	assert.Equal(t, ReasonUpdateFailed, synced.Reason)
+	assert.Contains(t, synced.Message, "cluster-root")
+
+	SetSyncedConditions(config, false)
+	assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, danav1alpha1.ConditionTypeReady))
+	assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, danav1alpha1.ConditionTypeSynced))
+	assert.True(t, meta.IsStatusConditionFalse(config.Status.Conditions, danav1alpha1.ConditionTypeDegraded))
+
+	// nothing is written when updates are disabled
+	SetSyncedConditions(config, true)
+	assert.True(t, meta.IsStatusConditionTrue(config.Status.Conditions, danav1alpha1.ConditionTypeReady))
+	synced = meta.FindStatusCondition(config.Status.Conditions, danav1alpha1.ConditionTypeSynced)
+	assert.Equal(t, metav1.ConditionFalse, synced.Status)
+	assert.Equal(t, ReasonUpdatesDisabled, synced.Reason)
+	assert.True(t, meta.IsStatusConditionFalse(config.Status.Conditions, danav1alpha1.ConditionTypeDegraded))
+
+	config.Status.ReservedResources = []danav1alpha1.ReservedResources{{NodeGroup: "gpu"}}
+	SetReservationsCondition(config)
+	reservations := meta.FindStatusCondition(config.Status.Conditions, danav1alpha1.ConditionTypeReservationsActive)
+	assert.Equal(t, metav1.ConditionTrue, reservations.Status)
+	assert.Contains(t, reservations.Message, "gpu")
+}
+
+func TestDescribeNodeGroupChange(t *testing.T) {
+	current := danav1alpha1.QuotaStatus{SecondaryRoot: "gpu", Nodes: 3}
+
+	assert.Equal(t, "node group gpu has 3 nodes", describeNodeGroupChange(nil, current))
+	assert.Equal(t, "node group gpu shrank by 2 nodes", describeNodeGroupChange(&danav1alpha1.QuotaStatus{Nodes: 5}, current))
+	assert.Equal(t, "node group gpu grew by 1 nodes", describeNodeGroupChange(&danav1alpha1.QuotaStatus{Nodes: 2}, current))
+	assert.Equal(t, "cpu=4,memory=8Gi", FormatResourceList(v1.ResourceList{
+		v1.ResourceMemory: resource.MustParse("8Gi"),
+		v1.ResourceCPU:    resource.MustParse("4"),
+	}))
+}
+
+func TestUpdateGroupReservations(t *testing.T) {
+	config := &danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{ReservedHoursToLive: 24},
+	}
+	nodeResources := v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), v1.ResourceMemory: resource.MustParse("8Gi")}
+	groupQuota := func(nodeNames ...string) danav1alpha1.QuotaStatus {
+		multiplied := v1.ResourceList{}
+		for range nodeNames {
+			multiplied = MergeTwoResourceList(multiplied, nodeResources)
+		}
+		return danav1alpha1.QuotaStatus{SecondaryRoot: "gpu", Nodes: len(nodeNames), NodeNames: nodeNames, Multiplied: multiplied}
+	}
+	fullQuota := groupQuota("node-1", "node-2", "node-3").Multiplied
+
+	// node-1 is removed, its resources are reserved
+	released, started := updateGroupReservations(config, groupQuota("node-2", "node-3"), fullQuota, []string{"node-1", "node-2", "node-3"})
+	assert.Empty(t, released)
+	if assert.Len(t, started, 1) {
+		assert.Equal(t, "node-1", started[0].NodeName)
+		assert.True(t, isEqualTo(nodeResources, started[0].Resources))
+	}
+
+	// 40 hours later node-2 is removed, it gets its own timestamp and node-1 keeps its timestamp
+	config.Status.ReservedResources[0].Timestamp = metav1.NewTime(time.Now().Add(-40 * time.Hour))
+	released, started = updateGroupReservations(config, groupQuota("node-3"), fullQuota, []string{"node-2", "node-3"})
+	assert.Empty(t, released)
+	if assert.Len(t, started, 1) {
+		assert.Equal(t, "node-2", started[0].NodeName)
+	}
+	assert.Len(t, config.Status.ReservedResources, 2)
+	assert.True(t, isReservedResourceExpired(config.Status.ReservedResources[0], *config))
+	assert.False(t, isReservedResourceExpired(config.Status.ReservedResources[1], *config))
+	assert.True(t, isEqualTo(nodeResources, getActiveReservedResourcesByGroup("gpu", *config)))
+
+	// node-2 comes back, only its reservation is released
+	released, started = updateGroupReservations(config, groupQuota("node-2", "node-3"), fullQuota, []string{"node-3"})
+	assert.Empty(t, started)
+	if assert.Len(t, released, 1) {
+		assert.Equal(t, "node-2", released[0].NodeName)
+	}
+	if assert.Len(t, config.Status.ReservedResources, 1) {
+		assert.Equal(t, "node-1", config.Status.ReservedResources[0].NodeName)
+	}
+}
+
+func TestSplitResourceList(t *testing.T) {
+	parts := splitResourceList(v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("5"),
+		v1.ResourceMemory: resource.MustParse("16Gi"),
+	}, 2)
+
+	if assert.Len(t, parts, 2) {
+		assert.True(t, resource.MustParse("2").Equal(parts[0][v1.ResourceCPU]))
+		assert.True(t, resource.MustParse("3").Equal(parts[1][v1.ResourceCPU]))
+		assert.Equal(t, "8Gi", parts[0].Memory().String())
+		assert.Equal(t, "8Gi", parts[1].Memory().String())
+	}
+}
+
+func TestParseReservationRequests(t *testing.T) {
+	requests, err := ParseReservationRequests(map[string]string{
+		danav1alpha1.ReleaseReservationsAnnotation:  "gpu, cpu-workloads/worker-3",
+		danav1alpha1.ExtendReservationsAnnotation:   "gpu/worker-1=12h",
+		danav1alpha1.ReservationRequesterAnnotation: "alice",
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, requests, 3) {
+		assert.Equal(t, danav1alpha1.ReservationActionRelease, requests[0].Action)
+		assert.Equal(t, "gpu", requests[0].NodeGroup)
+		assert.Equal(t, "worker-3", requests[1].NodeName)
+		assert.Equal(t, danav1alpha1.ReservationActionExtend, requests[2].Action)
+		assert.Equal(t, 12*time.Hour, requests[2].Duration.Duration)
+		assert.Equal(t, "alice", requests[2].RequestedBy)
+	}
+
+	_, err = ParseReservationRequests(map[string]string{danav1alpha1.ExtendReservationsAnnotation: "gpu"})
+	assert.Error(t, err)
+	_, err = ParseReservationRequests(map[string]string{danav1alpha1.ExtendReservationsAnnotation: "gpu=-1h"})
+	assert.Error(t, err)
+	_, err = ParseReservationRequests(map[string]string{danav1alpha1.ReleaseReservationsAnnotation: "/worker-3"})
+	assert.Error(t, err)
+}
+
+func TestApplyReservationRequests(t *testing.T) {
+	removedAt := metav1.NewTime(time.Now().Add(-20 * time.Hour))
+	config := &danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{ReservedHoursToLive: 24},
+		Status: danav1alpha1.NodeQuotaConfigStatus{
+			ReservedResources: []danav1alpha1.ReservedResources{
+				{NodeGroup: "gpu", NodeName: "worker-1", Resources: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}, Timestamp: removedAt},
+				{NodeGroup: "gpu", NodeName: "worker-2", Resources: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}, Timestamp: removedAt},
+			},
+			Quotas: []danav1alpha1.QuotaStatus{
+				{RootNamespace: "cluster-root", SecondaryRoot: "gpu", ReservedResources: v1.ResourceList{v1.ResourceCPU: resource.MustParse("8")}},
+			},
+		},
+	}
+
+	ApplyReservationRequests(config, []danav1alpha1.ReservationRequest{
+		{Action: danav1alpha1.ReservationActionRelease, NodeGroup: "gpu", NodeName: "worker-1", RequestedBy: "alice"},
+		{Action: danav1alpha1.ReservationActionExtend, NodeGroup: "gpu", Duration: &metav1.Duration{Duration: 10 * time.Hour}, RequestedBy: "bob"},
+	}, nil, logr.Discard())
+
+	if assert.Len(t, config.Status.ReservedResources, 1) {
+		extended := config.Status.ReservedResources[0]
+		assert.Equal(t, "worker-2", extended.NodeName)
+		assert.NotNil(t, extended.ExpirationTime)
+		assert.WithinDuration(t, removedAt.Add(34*time.Hour), extended.ExpirationTime.Time, time.Second)
+		assert.False(t, isReservedResourceExpired(extended, *config))
+	}
+	assert.True(t, resource.MustParse("4").Equal(config.Status.Quotas[0].ReservedResources[v1.ResourceCPU]))
+	if assert.Len(t, config.Status.ReservationRequests, 2) {
+		assert.Equal(t, "alice", config.Status.ReservationRequests[0].RequestedBy)
+		assert.Equal(t, 1, config.Status.ReservationRequests[0].Reservations)
+		assert.False(t, config.Status.ReservationRequests[1].RequestTime.IsZero())
+	}
+}
+
+func TestFilterEligibleNodes(t *testing.T) {
+	eligibility := &danav1alpha1.NodeEligibility{
+		ExcludeUnschedulable: true,
+		ExcludeConditions:    []danav1alpha1.NodeConditionRule{{Type: v1.NodeReady, Status: v1.ConditionFalse}},
+		ExcludeTaints:        []danav1alpha1.TaintRule{{Key: "maintenance", Effect: v1.TaintEffectNoSchedule}},
+	}
+	nodes := v1.NodeList{Items: []v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "healthy"}, Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: "maintenance", Effect: v1.TaintEffectPreferNoSchedule}}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "cordoned"}, Spec: v1.NodeSpec{Unschedulable: true}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "not-ready"}, Status: v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionFalse}}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tainted"}, Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: "maintenance", Value: "true", Effect: v1.TaintEffectNoSchedule}}}},
+	}}
+
+	eligible, excluded, reasons := filterEligibleNodes(nodes, eligibility)
+	if assert.Len(t, eligible.Items, 1) {
+		assert.Equal(t, "healthy", eligible.Items[0].Name)
+	}
+	assert.Len(t, excluded, 3)
+	assert.Equal(t, []string{"Unschedulable", "Condition Ready=False", "Taint maintenance=true:NoSchedule"}, reasons)
+
+	eligible, excluded, _ = filterEligibleNodes(nodes, nil)
+	assert.Len(t, eligible.Items, 4)
+	assert.Empty(t, excluded)
+}
+
+func TestUpdateGroupReservationsExcludedNode(t *testing.T) {
+	config := &danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{ReservedHoursToLive: 24},
+	}
+	groupQuota := danav1alpha1.QuotaStatus{
+		SecondaryRoot: "gpu",
+		NodeNames:     []string{"node-3"},
+		Multiplied:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")},
+		ExcludedNodes: []danav1alpha1.ExcludedNode{
+			{Name: "node-1", Reason: "Unschedulable", Resources: v1.ResourceList{v1.ResourceCPU: resource.MustParse("6")}},
+		},
+	}
+
+	// node-1 is cordoned and node-2 is deleted, node-1 is reserved with its own resources and node-2 gets the rest
+	_, started := updateGroupReservations(config, groupQuota, v1.ResourceList{v1.ResourceCPU: resource.MustParse("12")}, []string{"node-1", "node-2", "node-3"})
+	if assert.Len(t, started, 2) {
+		assert.Equal(t, "node-1", started[0].NodeName)
+		assert.True(t, resource.MustParse("6").Equal(started[0].Resources[v1.ResourceCPU]))
+		assert.Equal(t, "node-2", started[1].NodeName)
+		assert.True(t, resource.MustParse("4").Equal(started[1].Resources[v1.ResourceCPU]))
+	}
+}
+
+func TestMultiplyResourceListPrecision(t *testing.T) {
+	tests := []struct {
+		name         string
+		quantity     string
+		resourceName v1.ResourceName
+		multiplier   string
+		roundingMode danav1alpha1.RoundingMode
+		expected     string
+	}{
+		{name: "fractional cpu", resourceName: v1.ResourceCPU, quantity: "3500m", multiplier: "2", expected: "7"},
+		{name: "fractional cpu with fractional multiplier", resourceName: v1.ResourceCPU, quantity: "3500m", multiplier: "1.5", expected: "5250m"},
+		{name: "cpu rounded down to milli-units", resourceName: v1.ResourceCPU, quantity: "1", multiplier: "0.3333", expected: "333m"},
+		{name: "cpu rounded up to milli-units", resourceName: v1.ResourceCPU, quantity: "1", multiplier: "0.3333", roundingMode: danav1alpha1.RoundingModeCeil, expected: "334m"},
+		{name: "cpu rounded to nearest milli-unit", resourceName: v1.ResourceCPU, quantity: "5", multiplier: "0.3333", roundingMode: danav1alpha1.RoundingModeNearest, expected: "1667m"},
+		{name: "huge memory", resourceName: v1.ResourceMemory, quantity: "9007199254740993", multiplier: "3", expected: "27021597764222979"},
+		{name: "huge binary memory", resourceName: v1.ResourceMemory, quantity: "1000Ti", multiplier: "1.5", expected: "1500Ti"},
+		{name: "memory rounded down to bytes", resourceName: v1.ResourceMemory, quantity: "10", multiplier: "1.25", expected: "12"},
+		{name: "memory rounded up to bytes", resourceName: v1.ResourceMemory, quantity: "10", multiplier: "1.25", roundingMode: danav1alpha1.RoundingModeCeil, expected: "13"},
+		{name: "extended resource rounded down", resourceName: "nvidia.com/gpu", quantity: "3", multiplier: "1.5", expected: "4"},
+		{name: "extended resource rounded to nearest", resourceName: "nvidia.com/gpu", quantity: "3", multiplier: "1.5", roundingMode: danav1alpha1.RoundingModeNearest, expected: "5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources := v1.ResourceList{tt.resourceName: resource.MustParse(tt.quantity)}
+			factor := map[string]string{tt.resourceName.String(): tt.multiplier}
+			roundingModes := map[string]danav1alpha1.RoundingMode{tt.resourceName.String(): tt.roundingMode}
+
+			result := multiplyResourceList(resources, factor, roundingModes, nil, logr.Discard())
+			quantity := result[tt.resourceName]
+			assert.True(t, resource.MustParse(tt.expected).Equal(quantity), "expected %s, got %s", tt.expected, quantity.String())
+		})
+	}
+}
+
+func TestParseMultiplier(t *testing.T) {
+	multiplier, err := ParseMultiplier("1.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", multiplier.String())
+
+	_, err = ParseMultiplier("2x")
+	assert.Error(t, err)
+}
+
+func TestSetPlannedChange(t *testing.T) {
+	config := &danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{Mode: danav1alpha1.ConfigModeDryRun},
+		Status: danav1alpha1.NodeQuotaConfigStatus{
+			Quotas: []danav1alpha1.QuotaStatus{{
+				RootNamespace: "cluster-root",
+				SecondaryRoot: "gpu",
+				Quota:         v1.ResourceList{v1.ResourceCPU: resource.MustParse("12"), v1.ResourceMemory: resource.MustParse("16Gi")},
+			}},
+		},
+	}
+
+	setPlannedChange(config, "cluster-root", "gpu", v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("16"),
+		v1.ResourceMemory: resource.MustParse("16Gi"),
+		v1.ResourcePods:   resource.MustParse("110"),
+	})
+
+	quotaStatus := config.Status.Quotas[0]
+	assert.True(t, resource.MustParse("16").Equal(quotaStatus.Current[v1.ResourceCPU]))
+	assert.NotContains(t, quotaStatus.Current, v1.ResourcePods)
+	assert.True(t, resource.MustParse("-4").Equal(quotaStatus.PlannedChange[v1.ResourceCPU]))
+	assert.NotContains(t, quotaStatus.PlannedChange, v1.ResourceMemory)
+
+	SetSyncedConditions(config, false)
+	assert.Equal(t, ReasonDryRun, meta.FindStatusCondition(config.Status.Conditions, danav1alpha1.ConditionTypeSynced).Reason)
+}
+
+func TestProtectShrink(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    danav1alpha1.ShrinkPolicy
+		newCPU    string
+		expected  string
+		protected int
+	}{
+		{name: "clamp to allocated", newCPU: "8", expected: "12", protected: 1},
+		{name: "refuse keeps current", policy: danav1alpha1.ShrinkPolicyRefuse, newCPU: "8", expected: "16", protected: 1},
+		{name: "proceed writes calculated", policy: danav1alpha1.ShrinkPolicyProceed, newCPU: "8", expected: "8", protected: 1},
+		{name: "shrink above allocated", newCPU: "14", expected: "14", protected: 0},
+		{name: "growth", newCPU: "20", expected: "20", protected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newHard := v1.ResourceList{v1.ResourceCPU: resource.MustParse(tt.newCPU), v1.ResourceMemory: resource.MustParse("8Gi")}
+			currentHard := v1.ResourceList{v1.ResourceCPU: resource.MustParse("16"), v1.ResourceMemory: resource.MustParse("16Gi")}
+			allocated := v1.ResourceList{v1.ResourceCPU: resource.MustParse("12")}
+
+			protected := protectShrink(newHard, currentHard, allocated, tt.policy)
+			assert.Len(t, protected, tt.protected)
+			assert.True(t, resource.MustParse(tt.expected).Equal(newHard[v1.ResourceCPU]), "expected %s, got %s", tt.expected, newHard.Cpu().String())
+			assert.True(t, resource.MustParse("8Gi").Equal(newHard[v1.ResourceMemory]))
+		})
+	}
+}
+
+func TestRebalanceChildren(t *testing.T) {
+	newChild := func(name, cpu, memory string) danav1.Subnamespace {
+		child := danav1.Subnamespace{}
+		child.Name = name
+		child.Namespace = "gpu"
+		child.Spec.ResourceQuotaSpec.Hard = v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)}
+		return child
+	}
+	children := danav1.SubnamespaceList{Items: []danav1.Subnamespace{
+		newChild("team-a", "8", "8Gi"),
+		newChild("team-b", "4", "8Gi"),
+	}}
+	newHard := v1.ResourceList{v1.ResourceCPU: resource.MustParse("6"), v1.ResourceMemory: resource.MustParse("16Gi")}
+
+	rebalanced := rebalanceChildren(children, newHard, danav1alpha1.RebalancePolicy{Strategy: danav1alpha1.RebalanceStrategyProportional})
+	if assert.Len(t, rebalanced, 2) {
+		assert.True(t, resource.MustParse("4").Equal(rebalanced[0].Spec.ResourceQuotaSpec.Hard[v1.ResourceCPU]))
+		assert.True(t, resource.MustParse("2").Equal(rebalanced[1].Spec.ResourceQuotaSpec.Hard[v1.ResourceCPU]))
+		assert.True(t, resource.MustParse("8Gi").Equal(rebalanced[1].Spec.ResourceQuotaSpec.Hard[v1.ResourceMemory]))
+	}
+	assert.True(t, resource.MustParse("8").Equal(children.Items[0].Spec.ResourceQuotaSpec.Hard[v1.ResourceCPU]))
+
+	// team-b can give up only 4 cpu, so the rest of its share is taken from team-a
+	weighted := danav1alpha1.RebalancePolicy{Strategy: danav1alpha1.RebalanceStrategyWeighted, Weights: map[string]int32{"team-b": 3}}
+	rebalanced = rebalanceChildren(children, newHard, weighted)
+	if assert.Len(t, rebalanced, 2) {
+		assert.True(t, resource.MustParse("6").Equal(rebalanced[0].Spec.ResourceQuotaSpec.Hard[v1.ResourceCPU]))
+		assert.True(t, resource.MustParse("0").Equal(rebalanced[1].Spec.ResourceQuotaSpec.Hard[v1.ResourceCPU]))
+	}
+
+	exempt := danav1alpha1.RebalancePolicy{Strategy: danav1alpha1.RebalanceStrategyWeighted, Weights: map[string]int32{"team-a": 0}}
+	rebalanced = rebalanceChildren(children, v1.ResourceList{v1.ResourceCPU: resource.MustParse("10")}, exempt)
+	if assert.Len(t, rebalanced, 1) {
+		assert.Equal(t, "team-b", rebalanced[0].Name)
+		assert.True(t, resource.MustParse("2").Equal(rebalanced[0].Spec.ResourceQuotaSpec.Hard[v1.ResourceCPU]))
+	}
+
+	assert.Empty(t, rebalanceChildren(children, v1.ResourceList{v1.ResourceCPU: resource.MustParse("12")}, weighted))
+}
+
+func TestDescribeDrift(t *testing.T) {
+	lastSynced := v1.ResourceList{v1.ResourceCPU: resource.MustParse("16"), v1.ResourceMemory: resource.MustParse("16Gi"), v1.ResourcePods: resource.MustParse("110")}
+	current := v1.ResourceList{v1.ResourceCPU: resource.MustParse("20"), v1.ResourceMemory: resource.MustParse("16Gi")}
+	newResources := v1.ResourceList{v1.ResourceCPU: resource.MustParse("16"), v1.ResourceMemory: resource.MustParse("16Gi"), v1.ResourcePods: resource.MustParse("110")}
+
+	assert.Equal(t, []string{"cpu was changed to 20, set back to 16", "pods was removed, set back to 110"}, describeDrift(current, lastSynced, newResources))
+	assert.Empty(t, describeDrift(lastSynced, lastSynced, newResources))
+}
+
+func TestLastSyncedQuotas(t *testing.T) {
+	config := danav1alpha1.NodeQuotaConfig{
+		Status: danav1alpha1.NodeQuotaConfigStatus{
+			Quotas: []danav1alpha1.QuotaStatus{{RootNamespace: "cluster-root", Quota: v1.ResourceList{v1.ResourceCPU: resource.MustParse("16")}}},
+		},
+	}
+	assert.Nil(t, LastSyncedQuotas(config))
+
+	SetSyncedConditions(&config, true)
+	assert.Nil(t, LastSyncedQuotas(config))
+
+	SetSyncedConditions(&config, false)
+	lastSynced := LastSyncedQuotas(config)
+	if assert.Len(t, lastSynced, 1) {
+		assert.NotNil(t, findQuotaStatus(lastSynced, "cluster-root", ""))
+		assert.Nil(t, findQuotaStatus(lastSynced, "cluster-root", "gpu"))
+	}
+}
+
+func TestConfigTracksNode(t *testing.T) {
+	config := danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{
+			Roots: []danav1alpha1.SubnamespacesRoots{{
+				RootNamespace: "cluster-root",
+				SecondaryRoots: []danav1alpha1.NodeGroup{
+					{Name: "gpu", LabelSelector: map[string]string{"app": "gpu"}},
+					{Name: "cpu", NodeSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a"}}},
+					}},
+				},
+			}},
+		},
+	}
+
+	// nodes which no node group selects are listed in the unassigned nodes
+	assert.True(t, ConfigTracksNode(config, nil, map[string]string{"app": "gpu"}))
+	assert.True(t, ConfigTracksNode(config, nil, map[string]string{"zone": "a"}))
+	assert.True(t, ConfigTracksNode(config, nil, map[string]string{"app": "cpu", "zone": "b"}))
+	assert.True(t, ConfigTracksNode(config, nil, nil))
+
+	// with a catch-all node group, only the nodes which another config selects aren't tracked
+	config.Spec.Roots[0].SecondaryRoots = append(config.Spec.Roots[0].SecondaryRoots, danav1alpha1.NodeGroup{Name: "leftover", CatchAll: true})
+	other := danav1alpha1.NodeQuotaConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "batch"}}
+	other.Spec.Roots = []danav1alpha1.SubnamespacesRoots{{
+		RootNamespace:  "batch-root",
+		SecondaryRoots: []danav1alpha1.NodeGroup{{Name: "batch", LabelSelector: map[string]string{"app": "batch"}}},
+	}}
+	configs := []danav1alpha1.NodeQuotaConfig{config, other}
+	assert.True(t, ConfigTracksNode(config, configs, map[string]string{"app": "gpu"}))
+	assert.True(t, ConfigTracksNode(config, configs, map[string]string{"app": "gpu-typo"}))
+	assert.False(t, ConfigTracksNode(config, configs, map[string]string{"app": "batch"}))
+	assert.True(t, ConfigTracksNode(other, configs, map[string]string{"app": "gpu"}))
+}
+
+func TestSettleNodeSet(t *testing.T) {
+	config := &danav1alpha1.NodeQuotaConfig{}
+	_, pending := SettleNodeSet(config, "a")
+	assert.False(t, pending)
+
+	config.Spec.SettleWindow = &metav1.Duration{Duration: 10 * time.Minute}
+	_, pending = SettleNodeSet(config, "a")
+	assert.False(t, pending, "the first calculation doesn't wait")
+	SetNodeSetCalculated(config, "a")
+
+	_, pending = SettleNodeSet(config, "a")
+	assert.False(t, pending)
+
+	wait, pending := SettleNodeSet(config, "b")
+	assert.True(t, pending)
+	assert.InDelta(t, (10 * time.Minute).Seconds(), wait.Seconds(), 1)
+	assert.Equal(t, metav1.ConditionTrue, meta.FindStatusCondition(config.Status.Conditions, danav1alpha1.ConditionTypeRecalculationPending).Status)
+
+	// another change restarts the window
+	config.Status.PendingRecalculation.RecalculateAfter = metav1.NewTime(time.Now().Add(time.Minute))
+	wait, pending = SettleNodeSet(config, "c")
+	assert.True(t, pending)
+	assert.Equal(t, "c", config.Status.PendingRecalculation.NodeSetHash)
+	assert.Greater(t, wait, 9*time.Minute)
+
+	// the window passed without changes
+	config.Status.PendingRecalculation.RecalculateAfter = metav1.NewTime(time.Now().Add(-time.Second))
+	_, pending = SettleNodeSet(config, "c")
+	assert.False(t, pending)
+	SetNodeSetCalculated(config, "c")
+	assert.Nil(t, config.Status.PendingRecalculation)
+	assert.Equal(t, metav1.ConditionFalse, meta.FindStatusCondition(config.Status.Conditions, danav1alpha1.ConditionTypeRecalculationPending).Status)
+
+	// a change of the spec is recalculated without waiting
+	config.Generation++
+	_, pending = SettleNodeSet(config, "d")
+	assert.False(t, pending)
+}
+
+func TestHeldQuotas(t *testing.T) {
+	root := danav1alpha1.SubnamespacesRoots{RootNamespace: "cluster-root", SecondaryRoots: []danav1alpha1.NodeGroup{{Name: "gpu"}}}
+	config := &danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{ControlledResources: []string{"cpu"}, Roots: []danav1alpha1.SubnamespacesRoots{root}},
+		Status: danav1alpha1.NodeQuotaConfigStatus{Quotas: []danav1alpha1.QuotaStatus{{
+			RootNamespace:     "cluster-root",
+			SecondaryRoot:     "gpu",
+			Multiplied:        v1.ResourceList{"cpu": resource.MustParse("16")},
+			ReservedResources: v1.ResourceList{"cpu": resource.MustParse("4")},
+			Quota:             v1.ResourceList{"cpu": resource.MustParse("20")},
+		}}},
+	}
+
+	// the reservation was released while the recalculation is pending
+	rootResources, secondaryRoots, ok := HeldQuotas(config, root)
+	assert.True(t, ok)
+	assert.True(t, resource.MustParse("16").Equal(rootResources["cpu"]))
+	assert.Len(t, secondaryRoots, 1)
+	assert.Equal(t, "gpu", secondaryRoots[0].Name)
+	assert.True(t, resource.MustParse("16").Equal(secondaryRoots[0].Spec.ResourceQuotaSpec.Hard["cpu"]))
+	assert.True(t, resource.MustParse("16").Equal(getQuotaStatus(*config, "cluster-root", "gpu").Quota["cpu"]))
+	assert.True(t, resource.MustParse("16").Equal(getQuotaStatus(*config, "cluster-root", "").Quota["cpu"]))
+
+	// a secondary root which was never calculated waits for the recalculation
+	root.SecondaryRoots = append(root.SecondaryRoots, danav1alpha1.NodeGroup{Name: "cpu-workloads"})
+	_, _, ok = HeldQuotas(config, root)
+	assert.False(t, ok)
+}
+
+func TestFindNodeOverlaps(t *testing.T) {
+	newNode := func(name string, nodeLabels map[string]string) v1.Node {
+		node := v1.Node{}
+		node.Name = name
+		node.Labels = nodeLabels
+		return node
+	}
+	nodes := []v1.Node{
+		newNode("worker-1", map[string]string{"app": "gpu", "pool": "shared"}),
+		newNode("worker-2", map[string]string{"app": "gpu"}),
+		newNode("worker-3", map[string]string{"app": "cpu"}),
+	}
+	config := danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{
+			Roots: []danav1alpha1.SubnamespacesRoots{{
+				RootNamespace: "cluster-root",
+				SecondaryRoots: []danav1alpha1.NodeGroup{
+					{Name: "gpu", LabelSelector: map[string]string{"app": "gpu"}},
+					{Name: "shared", LabelSelector: map[string]string{"pool": "shared"}, Priority: 10},
+				},
+			}},
+		},
+	}
+	other := danav1alpha1.NodeQuotaConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"},
+		Spec: danav1alpha1.NodeQuotaConfigSpec{
+			Roots: []danav1alpha1.SubnamespacesRoots{{
+				RootNamespace:  "other-root",
+				SecondaryRoots: []danav1alpha1.NodeGroup{{Name: "cpu", LabelSelector: map[string]string{"app": "cpu"}}, {Name: "gpu", LabelSelector: map[string]string{"app": "gpu"}}},
+			}},
+		},
+	}
+
+	overlaps, err := findNodeOverlaps(nodes, config, []danav1alpha1.NodeQuotaConfig{other})
+	assert.NoError(t, err)
+	if assert.Len(t, overlaps, 2) {
+		assert.Equal(t, "worker-1", overlaps[0].NodeName)
+		assert.Equal(t, []string{"gpu", "shared"}, overlaps[0].NodeGroups)
+		assert.Equal(t, []string{"default/other/gpu"}, overlaps[0].OtherConfigs)
+		assert.Empty(t, overlaps[0].AssignedTo)
+		assert.Equal(t, "worker-2", overlaps[1].NodeName)
+		assert.Equal(t, []string{"gpu"}, overlaps[1].NodeGroups)
+	}
+
+	config.Spec.OverlapPolicy = danav1alpha1.OverlapPolicyPriority
+	overlaps, err = findNodeOverlaps(nodes, config, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, overlaps, 1) {
+		assert.Equal(t, "shared", overlaps[0].AssignedTo)
+	}
+
+	gpuNodes := filterAssignedNodes(v1.NodeList{Items: nodes}, "gpu", overlaps)
+	assert.Len(t, gpuNodes.Items, 2)
+	assert.Equal(t, "worker-2", gpuNodes.Items[0].Name)
+	assert.Len(t, filterAssignedNodes(v1.NodeList{Items: nodes}, "shared", overlaps).Items, 3)
+
+	config.Status.OverlappingNodes = overlaps
+	SetNodesOverlappingCondition(&config)
+	condition := meta.FindStatusCondition(config.Status.Conditions, danav1alpha1.ConditionTypeNodesOverlapping)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "nodes are selected by more than one node group: worker-1 (gpu, shared) counted in shared", condition.Message)
+}
+
+func TestFindUnassignedNodes(t *testing.T) {
+	newNode := func(name string, nodeLabels map[string]string) v1.Node {
+		node := v1.Node{}
+		node.Name = name
+		node.Labels = nodeLabels
+		node.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), v1.ResourceMemory: resource.MustParse("16Gi")}
+		return node
+	}
+	nodes := v1.NodeList{Items: []v1.Node{
+		newNode("worker-1", map[string]string{"app": "gpu"}),
+		newNode("worker-3", map[string]string{"app": "gpu-typo"}),
+		newNode("worker-2", nil),
+	}}
+	config := danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{
+			ControlledResources: []string{"cpu"},
+			Roots: []danav1alpha1.SubnamespacesRoots{{
+				RootNamespace:  "cluster-root",
+				SecondaryRoots: []danav1alpha1.NodeGroup{{Name: "gpu", LabelSelector: map[string]string{"app": "gpu"}}},
+			}},
+		},
+	}
+
+	unassigned := findUnassignedNodes(nodes, config)
+	assert.Equal(t, 2, unassigned.Nodes)
+	assert.Equal(t, []string{"worker-2", "worker-3"}, unassigned.NodeNames)
+	assert.True(t, resource.MustParse("8").Equal(unassigned.Allocatable[v1.ResourceCPU]))
+	assert.NotContains(t, unassigned.Allocatable, v1.ResourceMemory)
+
+	config.Spec.Roots[0].SecondaryRoots = append(config.Spec.Roots[0].SecondaryRoots, danav1alpha1.NodeGroup{Name: "leftover", CatchAll: true})
+	assert.Equal(t, 0, findUnassignedNodes(nodes, config).Nodes)
+
+	leftover := filterLeftoverNodes(nodes, config, nil)
+	if assert.Len(t, leftover.Items, 2) {
+		assert.Equal(t, "worker-3", leftover.Items[0].Name)
+		assert.Equal(t, "worker-2", leftover.Items[1].Name)
+	}
+}
+
+func TestFilterLeftoverNodesOtherConfigs(t *testing.T) {
+	newNode := func(name string, nodeLabels map[string]string) v1.Node {
+		node := v1.Node{}
+		node.Name = name
+		node.Labels = nodeLabels
+		return node
+	}
+	nodes := v1.NodeList{Items: []v1.Node{
+		newNode("worker-1", map[string]string{"app": "gpu"}),
+		newNode("worker-2", map[string]string{"app": "batch"}),
+		newNode("worker-3", nil),
+	}}
+	newConfig := func(name string, secondaryRoots ...danav1alpha1.NodeGroup) danav1alpha1.NodeQuotaConfig {
+		config := danav1alpha1.NodeQuotaConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+		config.Spec.Roots = []danav1alpha1.SubnamespacesRoots{{RootNamespace: name + "-root", SecondaryRoots: secondaryRoots}}
+		return config
+	}
+	config := newConfig("cluster",
+		danav1alpha1.NodeGroup{Name: "gpu", LabelSelector: map[string]string{"app": "gpu"}},
+		danav1alpha1.NodeGroup{Name: "leftover", CatchAll: true})
+	batch := newConfig("batch",
+		danav1alpha1.NodeGroup{Name: "batch", LabelSelector: map[string]string{"app": "batch"}},
+		danav1alpha1.NodeGroup{Name: "batch-leftover", CatchAll: true})
+
+	// the nodes of the batch node group are sold by the other config, only the rest is left for the catch-all node group
+	leftover := filterLeftoverNodes(nodes, config, otherConfigs(config, []danav1alpha1.NodeQuotaConfig{config, batch}))
+	if assert.Len(t, leftover.Items, 1) {
+		assert.Equal(t, "worker-3", leftover.Items[0].Name)
+	}
+	leftover = filterLeftoverNodes(nodes, batch, otherConfigs(batch, []danav1alpha1.NodeQuotaConfig{config, batch}))
+	if assert.Len(t, leftover.Items, 1) {
+		assert.Equal(t, "worker-3", leftover.Items[0].Name)
+	}
+
+	// a config in DryRun mode never writes, so it doesn't take the nodes of the catch-all node group
+	batch.Spec.Mode = danav1alpha1.ConfigModeDryRun
+	assert.Len(t, filterLeftoverNodes(nodes, config, []danav1alpha1.NodeQuotaConfig{batch}).Items, 2)
+}
+
+func TestConfigConflicts(t *testing.T) {
+	newConfig := func(name string, created time.Time, rootNamespace string, secondaryRoots ...string) danav1alpha1.NodeQuotaConfig {
+		config := danav1alpha1.NodeQuotaConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, CreationTimestamp: metav1.NewTime(created)}}
+		root := danav1alpha1.SubnamespacesRoots{RootNamespace: rootNamespace}
+		for _, secondaryRoot := range secondaryRoots {
+			root.SecondaryRoots = append(root.SecondaryRoots, danav1alpha1.NodeGroup{Name: secondaryRoot})
+		}
+		config.Spec.Roots = []danav1alpha1.SubnamespacesRoots{root}
+		return config
+	}
+	now := time.Now()
+	first := newConfig("first", now.Add(-time.Hour), "cluster-root", "gpu", "cpu")
+	second := newConfig("second", now, "cluster-root", "gpu")
+	third := newConfig("third", now, "other-root", "cpu", "storage")
+	configs := []danav1alpha1.NodeQuotaConfig{first, second, third}
+
+	assert.Empty(t, ConfigConflicts(first, configs))
+	assert.Equal(t, []danav1alpha1.ConfigConflict{{RootNamespace: "cluster-root", ManagedBy: "default/first"}}, ConfigConflicts(second, configs))
+	assert.Equal(t, []danav1alpha1.ConfigConflict{{RootNamespace: "other-root", SecondaryRoot: "cpu", ManagedBy: "default/first"}}, ConfigConflicts(third, configs))
+
+	// a config which is being created is the newest
+	created := newConfig("new", time.Time{}, "other-root", "storage")
+	created.CreationTimestamp = metav1.Time{}
+	assert.Equal(t, []danav1alpha1.ConfigConflict{{RootNamespace: "other-root", ManagedBy: "default/third"}}, ConfigConflicts(created, configs))
+	assert.True(t, ClaimsOverlap(created, third))
+	assert.False(t, ClaimsOverlap(created, second))
+
+	second.Status.Conflicts = ConfigConflicts(second, configs)
+	SetConflictedConditions(&second)
+	assert.Equal(t, ReasonConflicted, meta.FindStatusCondition(second.Status.Conditions, danav1alpha1.ConditionTypeReady).Reason)
+	assert.Equal(t, "root cluster-root is managed by NodeQuotaConfig default/first", meta.FindStatusCondition(second.Status.Conditions, danav1alpha1.ConditionTypeConflicted).Message)
+
+	// a config in DryRun mode is never conflicted, and doesn't claim the roots of the newer configs
+	second.Spec.Mode = danav1alpha1.ConfigModeDryRun
+	assert.Empty(t, ConfigConflicts(second, configs))
+	first.Spec.Mode = danav1alpha1.ConfigModeDryRun
+	configs = []danav1alpha1.NodeQuotaConfig{first, second, third}
+	assert.Empty(t, ConfigConflicts(third, configs))
+	second.Spec.Mode = danav1alpha1.ConfigModeEnforce
+	assert.Empty(t, ConfigConflicts(second, configs))
+
+	// the DryRun config shadows the config which writes the quotas of the same root
+	assert.True(t, ShadowsManagingConfig(first, []danav1alpha1.NodeQuotaConfig{first, second, third}))
+	assert.False(t, ShadowsManagingConfig(second, []danav1alpha1.NodeQuotaConfig{first, second, third}))
+	assert.False(t, ShadowsManagingConfig(first, []danav1alpha1.NodeQuotaConfig{first}))
+}
+
+func TestRestoreHard(t *testing.T) {
+	hard := v1.ResourceList{
+		"cpu":    resource.MustParse("40"),
+		"memory": resource.MustParse("100Gi"),
+		"pods":   resource.MustParse("500"),
+	}
+	snapshot := danav1alpha1.QuotaSnapshot{Hard: v1.ResourceList{"cpu": resource.MustParse("10")}}
+
+	// memory was not in the snapshot so it is removed, and pods is not controlled so it is kept
+	restored := restoreHard(hard, snapshot, []string{"cpu", "memory"})
+	assert.Equal(t, v1.ResourceList{"cpu": resource.MustParse("10"), "pods": resource.MustParse("500")}, restored)
+	assert.Equal(t, resource.MustParse("40"), hard["cpu"])
+}
+
+func TestSnapshotQuotasManagedBefore(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, danav1.AddToScheme(scheme))
+
+	// the root quota was written by the plugin before the snapshots were added, and the secondary root wasn't
+	rootRQ := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root", ManagedFields: []metav1.ManagedFieldsEntry{
+			ownedQuotaFields(FieldManager, metav1.ManagedFieldsOperationUpdate, "cpu"),
+		}},
+		Spec: v1.ResourceQuotaSpec{Hard: v1.ResourceList{"cpu": resource.MustParse("16")}},
+	}
+	sns := &danav1.Subnamespace{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "gpu"}}
+	sns.Spec.ResourceQuotaSpec.Hard = v1.ResourceList{"cpu": resource.MustParse("10")}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(rootRQ, sns).Build()
+
+	root := danav1alpha1.SubnamespacesRoots{RootNamespace: "cluster-root", SecondaryRoots: []danav1alpha1.NodeGroup{{Name: "gpu"}}}
+	config := &danav1alpha1.NodeQuotaConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config"},
+		Spec:       danav1alpha1.NodeQuotaConfigSpec{ControlledResources: []string{"cpu"}, Roots: []danav1alpha1.SubnamespacesRoots{root}},
+	}
+	assert.NoError(t, SnapshotQuotas(context.Background(), c, root, config))
+	assert.True(t, findQuotaSnapshot(config.Status.QuotaSnapshots, "cluster-root", "").ManagedBefore)
+	assert.False(t, findQuotaSnapshot(config.Status.QuotaSnapshots, "cluster-root", "gpu").ManagedBefore)
+
+	// the plugin changes both quotas, and only the secondary root is restored
+	sns.Spec.ResourceQuotaSpec.Hard = v1.ResourceList{"cpu": resource.MustParse("8")}
+	assert.NoError(t, c.Update(context.Background(), sns))
+	recorder := record.NewFakeRecorder(10)
+	assert.NoError(t, RestoreQuotaSnapshots(context.Background(), c, recorder, config, logr.Discard()))
+
+	restored := &danav1.Subnamespace{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(sns), restored))
+	assert.True(t, resource.MustParse("10").Equal(restored.Spec.ResourceQuotaSpec.Hard["cpu"]))
+	events := []string{<-recorder.Events, <-recorder.Events, <-recorder.Events}
+	assert.Contains(t, events, "Warning QuotaRestoreSkipped quota of root cluster-root was left as it is, it was already managed by the plugin when NodeQuotaConfig default/config took its snapshot, so the quota from before is unknown")
+}
+
+func TestDeleteStaleQuotaSnapshots(t *testing.T) {
+	config := danav1alpha1.NodeQuotaConfig{}
+	config.Spec.Roots = []danav1alpha1.SubnamespacesRoots{{RootNamespace: "cluster-root", SecondaryRoots: []danav1alpha1.NodeGroup{{Name: "gpu"}}}}
+	config.Status.QuotaSnapshots = []danav1alpha1.QuotaSnapshot{
+		{RootNamespace: "cluster-root"},
+		{RootNamespace: "cluster-root", SecondaryRoot: "gpu"},
+		{RootNamespace: "cluster-root", SecondaryRoot: "cpu"},
+		{RootNamespace: "other-root"},
+	}
+
+	DeleteStaleQuotaSnapshots(&config)
+	assert.Equal(t, []danav1alpha1.QuotaSnapshot{
+		{RootNamespace: "cluster-root"},
+		{RootNamespace: "cluster-root", SecondaryRoot: "gpu"},
+	}, config.Status.QuotaSnapshots)
+}
+
+func TestPausedRootResources(t *testing.T) {
+	root := danav1alpha1.SubnamespacesRoots{RootNamespace: "cluster-root", SecondaryRoots: []danav1alpha1.NodeGroup{{Name: "gpu"}, {Name: "cpu"}}}
+	config := danav1alpha1.NodeQuotaConfig{}
+	config.Spec.Roots = []danav1alpha1.SubnamespacesRoots{root}
+	config.Annotations = map[string]string{danav1alpha1.PausedNodeGroupsAnnotation: "gpu"}
+	config.Status.Quotas = []danav1alpha1.QuotaStatus{
+		{RootNamespace: "cluster-root", SecondaryRoot: "gpu", Quota: v1.ResourceList{"cpu": resource.MustParse("8")}, Current: v1.ResourceList{"cpu": resource.MustParse("12")}},
+		{RootNamespace: "cluster-root", SecondaryRoot: "cpu", Quota: v1.ResourceList{"cpu": resource.MustParse("20")}},
+	}
+
+	assert.False(t, IsConfigPaused(config))
+	assert.True(t, IsNodeGroupPaused(config, "gpu"))
+	assert.False(t, IsNodeGroupPaused(config, "cpu"))
+	assert.True(t, IsRootPaused(config, root))
+	assert.Equal(t, []string{"gpu"}, PausedNodeGroups(config))
+
+	// the paused secondary root keeps its current quota, so it is counted in the root instead of the calculated one
+	resources := pausedRootResources(root, v1.ResourceList{"cpu": resource.MustParse("28")}, config)
+	assert.True(t, resource.MustParse("32").Equal(resources["cpu"]))
+
+	config.Annotations = map[string]string{danav1alpha1.PausedAnnotation: "true"}
+	assert.True(t, IsConfigPaused(config))
+	assert.Equal(t, []string{"gpu", "cpu"}, PausedNodeGroups(config))
+}
+
+func TestOldestReservation(t *testing.T) {
+	now := time.Now()
+	config := danav1alpha1.NodeQuotaConfig{Spec: danav1alpha1.NodeQuotaConfigSpec{ReservedHoursToLive: 24}}
+	config.Status.ReservedResources = []danav1alpha1.ReservedResources{
+		{NodeGroup: "gpu", NodeName: "expired", Timestamp: metav1.NewTime(now.Add(-25 * time.Hour))},
+		{NodeGroup: "gpu", NodeName: "oldest", Timestamp: metav1.NewTime(now.Add(-3 * time.Hour))},
+		{NodeGroup: "gpu", NodeName: "newest", Timestamp: metav1.NewTime(now.Add(-1 * time.Hour))},
+		{NodeGroup: "cpu", NodeName: "other", Timestamp: metav1.NewTime(now.Add(-5 * time.Hour))},
+	}
+
+	oldest, ok := OldestReservation(config, "gpu")
+	assert.True(t, ok)
+	assert.True(t, oldest.Time.Equal(now.Add(-3*time.Hour)))
+
+	_, ok = OldestReservation(config, "storage")
+	assert.False(t, ok)
+}
+
+func TestPatchQuota(t *testing.T) {
+	rootRQ := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"},
+		Spec:       v1.ResourceQuotaSpec{Hard: v1.ResourceList{"cpu": resource.MustParse("10"), "pods": resource.MustParse("500")}},
+	}
+	conflicts := 0
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(rootRQ).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, object client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			// the first attempt conflicts with a change of the pods quota by someone else
+			if conflicts == 0 {
+				conflicts++
+				changed := &v1.ResourceQuota{}
+				_ = c.Get(ctx, client.ObjectKeyFromObject(object), changed)
+				changed.Spec.Hard["pods"] = resource.MustParse("600")
+				_ = c.Update(ctx, changed)
+				return errors.NewConflict(v1.Resource("resourcequotas"), object.GetName(), nil)
+			}
+			return c.Patch(ctx, object, patch, opts...)
+		},
+	}).Build()
+
+	quota := &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}
+	err := patchQuota(context.Background(), c, quota, func() {
+		quota.Spec.Hard["cpu"] = resource.MustParse("16")
+	}, "cluster-root", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, conflicts)
+
+	patched := &v1.ResourceQuota{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(rootRQ), patched))
+	assert.True(t, resource.MustParse("16").Equal(patched.Spec.Hard["cpu"]))
+	assert.True(t, resource.MustParse("600").Equal(patched.Spec.Hard["pods"]))
+}
+
+func TestQuotaApplyObject(t *testing.T) {
+	hard := v1.ResourceList{"cpu": resource.MustParse("16"), "requests.nvidia.com/gpu": resource.MustParse("4")}
+
+	sns := quotaApplyObject(&danav1.Subnamespace{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "gpu"}}, hard)
+	assert.Equal(t, "dana.hns.io/v1, Kind=Subnamespace", sns.GroupVersionKind().String())
+	assert.Equal(t, "cluster-root", sns.GetNamespace())
+	assert.Equal(t, "gpu", sns.GetName())
+	assert.Equal(t, map[string]interface{}{"resourcequota": map[string]interface{}{"hard": map[string]interface{}{"cpu": "16", "requests.nvidia.com/gpu": "4"}}}, sns.Object["spec"])
+
+	rootRQ := quotaApplyObject(&v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}, hard)
+	assert.Equal(t, "/v1, Kind=ResourceQuota", rootRQ.GroupVersionKind().String())
+	assert.Equal(t, map[string]interface{}{"hard": map[string]interface{}{"cpu": "16", "requests.nvidia.com/gpu": "4"}}, rootRQ.Object["spec"])
+}
+
+// applyConflictClient returns a client with the given object which emulates the server-side apply of the API server:
+// an apply without force conflicts on the cpu quota, which is owned by the given manager, and every forced apply is counted.
+func applyConflictClient(scheme *runtime.Scheme, object client.Object, conflictWith string, forced *int) client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(object).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, object client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			patchOptions := &client.PatchOptions{}
+			patchOptions.ApplyOptions(opts)
+			if patchOptions.Force != nil && *patchOptions.Force {
+				*forced++
+				return nil
+			}
+			return errors.NewApplyConflict([]metav1.StatusCause{{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: fmt.Sprintf("conflict with %q", conflictWith),
+				Field:   ".spec.hard.cpu",
+			}}, "Apply failed with 1 conflict")
+		},
+	}).Build()
+}
+
+// ownedQuotaFields returns the managed fields entry of a manager which owns the given resources of a root ResourceQuota.
+func ownedQuotaFields(manager string, operation metav1.ManagedFieldsOperationType, resourceNames ...string) metav1.ManagedFieldsEntry {
+	hard := map[string]interface{}{}
+	for _, resourceName := range resourceNames {
+		hard["f:"+resourceName] = map[string]interface{}{}
+	}
+	raw, _ := json.Marshal(map[string]interface{}{"f:spec": map[string]interface{}{"f:hard": hard}})
+	return metav1.ManagedFieldsEntry{Manager: manager, Operation: operation, FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: raw}}
+}
+
+func TestApplyQuotaFieldConflict(t *testing.T) {
+	// the cpu quota is owned by another applier, so it conflicts even though the plugin synced it
+	rootRQ := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root", ManagedFields: []metav1.ManagedFieldsEntry{
+			ownedQuotaFields(FieldManager, metav1.ManagedFieldsOperationApply, "memory"),
+			ownedQuotaFields("quota-operator", metav1.ManagedFieldsOperationApply, "cpu"),
+		}},
+		Spec: v1.ResourceQuotaSpec{Hard: v1.ResourceList{"cpu": resource.MustParse("20"), "memory": resource.MustParse("16Gi")}},
+	}
+	forced := 0
+	c := applyConflictClient(clientgoscheme.Scheme, rootRQ, "quota-operator", &forced)
+
+	hard := v1.ResourceList{"cpu": resource.MustParse("16"), "memory": resource.MustParse("16Gi")}
+	err := applyQuota(context.Background(), c, &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}, hard, hard, false, "root cluster-root", "cluster-root", "")
+	assert.Equal(t, []string{`root cluster-root: .spec.hard.cpu (conflict with "quota-operator")`}, FieldConflicts(err))
+	assert.Equal(t, 0, forced)
+
+	err = applyQuota(context.Background(), c, &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}, hard, hard, true, "root cluster-root", "cluster-root", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, forced)
+	assert.Nil(t, FieldConflicts(errors.NewConflict(v1.Resource("resourcequotas"), "cluster-root", nil)))
+}
+
+func TestApplyQuotaRetry(t *testing.T) {
+	rootRQ := &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}
+	attempts := 0
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(rootRQ).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, object client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			attempts++
+			// the quota changed between reading and applying it in the first attempt
+			if attempts == 1 {
+				return errors.NewConflict(v1.Resource("resourcequotas"), "cluster-root", goerrors.New("the object has been modified"))
+			}
+			return nil
+		},
+	}).Build()
+
+	hard := v1.ResourceList{"cpu": resource.MustParse("16")}
+	err := applyQuota(context.Background(), c, &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}, hard, nil, false, "root cluster-root", "cluster-root", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// the conflicts with other field managers aren't retried
+	rootRQ.ManagedFields = []metav1.ManagedFieldsEntry{ownedQuotaFields("quota-operator", metav1.ManagedFieldsOperationApply, "cpu")}
+	forced := 0
+	conflictClient := applyConflictClient(clientgoscheme.Scheme, rootRQ, "quota-operator", &forced)
+	err = applyQuota(context.Background(), conflictClient, &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}, hard, nil, false, "root cluster-root", "cluster-root", "")
+	assert.NotNil(t, FieldConflicts(err))
+	assert.False(t, isRetriableConflict(err))
+}
+
+func TestApplyQuotaReleasedResources(t *testing.T) {
+	// the memory was removed from the controlled resources
+	rootRQ := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root", ManagedFields: []metav1.ManagedFieldsEntry{
+			ownedQuotaFields(FieldManager, metav1.ManagedFieldsOperationApply, "cpu", "memory"),
+		}},
+		Spec: v1.ResourceQuotaSpec{Hard: v1.ResourceList{"cpu": resource.MustParse("16"), "memory": resource.MustParse("16Gi")}},
+	}
+	applied := map[string]interface{}{}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(rootRQ).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, object client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			patchOptions := &client.PatchOptions{}
+			patchOptions.ApplyOptions(opts)
+			applied[patchOptions.FieldManager] = object.(*unstructured.Unstructured).Object["spec"]
+			return nil
+		},
+	}).Build()
+
+	hard := v1.ResourceList{"cpu": resource.MustParse("16")}
+	err := applyQuota(context.Background(), c, &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}, hard, hard, false, "root cluster-root", "cluster-root", "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		ReleasedFieldManager: map[string]interface{}{"hard": map[string]interface{}{"memory": "16Gi"}},
+		FieldManager:         map[string]interface{}{"hard": map[string]interface{}{"cpu": "16"}},
+	}, applied)
+}
+
+func TestApplyQuotaTakeOver(t *testing.T) {
+	hard := v1.ResourceList{"cpu": resource.MustParse("16"), "memory": resource.MustParse("16Gi")}
+	tests := []struct {
+		name          string
+		managedFields []metav1.ManagedFieldsEntry
+		synced        v1.ResourceList
+		forced        bool
+	}{
+		{
+			// before the upgrade, the plugin wrote the quota with updates, and the creator of the quota owns the memory
+			name: "upgrade from updates of the plugin",
+			managedFields: []metav1.ManagedFieldsEntry{
+				ownedQuotaFields("kubectl-create", metav1.ManagedFieldsOperationUpdate, "memory"),
+				ownedQuotaFields(FieldManager, metav1.ManagedFieldsOperationUpdate, "cpu"),
+			},
+			forced: true,
+		},
+		{
+			name: "first write of a quota set by a human",
+			managedFields: []metav1.ManagedFieldsEntry{
+				ownedQuotaFields("kubectl-create", metav1.ManagedFieldsOperationUpdate, "cpu", "memory"),
+			},
+			forced: false,
+		},
+		{
+			name: "quota of another applier",
+			managedFields: []metav1.ManagedFieldsEntry{
+				ownedQuotaFields("quota-operator", metav1.ManagedFieldsOperationApply, "cpu"),
+			},
+			synced: hard,
+			forced: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rootRQ := &v1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root", ManagedFields: test.managedFields},
+				Spec:       v1.ResourceQuotaSpec{Hard: v1.ResourceList{"cpu": resource.MustParse("10"), "memory": resource.MustParse("16Gi")}},
+			}
+			forced := 0
+			c := applyConflictClient(clientgoscheme.Scheme, rootRQ, "kubectl-create", &forced)
+
+			err := applyQuota(context.Background(), c, &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root"}}, hard, test.synced, false, "root cluster-root", "cluster-root", "")
+			if test.forced {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, forced)
+			} else {
+				assert.NotNil(t, FieldConflicts(err))
+				assert.Equal(t, 0, forced)
+			}
+		})
+	}
+}
+
+func TestUpdateRootSubnamespaceDriftReverted(t *testing.T) {
+	config := &danav1alpha1.NodeQuotaConfig{Spec: danav1alpha1.NodeQuotaConfigSpec{ControlledResources: []string{"cpu", "memory"}}}
+	root := danav1alpha1.SubnamespacesRoots{RootNamespace: "cluster-root"}
+	hard := v1.ResourceList{"cpu": resource.MustParse("16"), "memory": resource.MustParse("16Gi")}
+	lastSynced := []danav1alpha1.QuotaStatus{{RootNamespace: "cluster-root", Quota: hard}}
+
+	tests := []struct {
+		name          string
+		current       v1.ResourceList
+		managedFields []metav1.ManagedFieldsEntry
+		event         string
+	}{
+		{
+			// the plugin still owns the memory
+			name:    "some controlled resources changed",
+			current: v1.ResourceList{"cpu": resource.MustParse("20"), "memory": resource.MustParse("16Gi")},
+			managedFields: []metav1.ManagedFieldsEntry{
+				ownedQuotaFields(FieldManager, metav1.ManagedFieldsOperationApply, "memory"),
+				ownedQuotaFields("kubectl-edit", metav1.ManagedFieldsOperationUpdate, "cpu"),
+			},
+			event: "Warning QuotaDriftReverted manual change of the quota of root cluster-root was reverted: cpu was changed to 20, set back to 16",
+		},
+		{
+			// the plugin doesn't own any resource anymore
+			name:    "every controlled resource changed",
+			current: v1.ResourceList{"cpu": resource.MustParse("20"), "memory": resource.MustParse("20Gi")},
+			managedFields: []metav1.ManagedFieldsEntry{
+				ownedQuotaFields("kubectl-edit", metav1.ManagedFieldsOperationUpdate, "cpu", "memory"),
+			},
+			event: "Warning QuotaDriftReverted manual change of the quota of root cluster-root was reverted: cpu was changed to 20, set back to 16, memory was changed to 20Gi, set back to 16Gi",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rootRQ := &v1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "cluster-root", ManagedFields: test.managedFields},
+				Spec:       v1.ResourceQuotaSpec{Hard: test.current},
+			}
+			forced := 0
+			c := applyConflictClient(clientgoscheme.Scheme, rootRQ, "kubectl-edit", &forced)
+			recorder := record.NewFakeRecorder(10)
+
+			err := UpdateRootSubnamespace(context.Background(), hard, root, logr.Discard(), c, recorder, config, lastSynced)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, forced)
+			assert.Equal(t, test.event, <-recorder.Events)
+		})
+	}
+}
+
+func TestMultiplyResourceListSystemClaim(t *testing.T) {
+	allocatable := v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), v1.ResourceMemory: resource.MustParse("8Gi")}
+	factor := map[string]string{"cpu": "2"}
+	claim := map[string]resource.Quantity{"cpu": resource.MustParse("1"), "memory": resource.MustParse("1Gi")}
+
+	// before the claim was subtracted, two nodes were calculated as 16 CPUs and 16Gi of memory
+	unclaimed := MergeTwoResourceList(multiplyResourceList(allocatable, factor, nil, nil, logr.Discard()), multiplyResourceList(allocatable, factor, nil, nil, logr.Discard()))
+	assert.True(t, resource.MustParse("16").Equal(unclaimed[v1.ResourceCPU]))
+	assert.True(t, resource.MustParse("16Gi").Equal(unclaimed[v1.ResourceMemory]))
+
+	// the claim of every node is subtracted before the multipliers are applied
+	claimed := MergeTwoResourceList(multiplyResourceList(allocatable, factor, nil, claim, logr.Discard()), multiplyResourceList(allocatable, factor, nil, claim, logr.Discard()))
+	assert.True(t, resource.MustParse("12").Equal(claimed[v1.ResourceCPU]))
+	assert.True(t, resource.MustParse("14Gi").Equal(claimed[v1.ResourceMemory]))
+
+	// the allocatable resources are left untouched
+	assert.True(t, resource.MustParse("4").Equal(allocatable[v1.ResourceCPU]))
+}
+
+func TestSetPlannedRebalance(t *testing.T) {
+	config := &danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{Mode: danav1alpha1.ConfigModeDryRun, ControlledResources: []string{"cpu", "memory"}},
+		Status: danav1alpha1.NodeQuotaConfigStatus{
+			Quotas: []danav1alpha1.QuotaStatus{{RootNamespace: "cluster-root", SecondaryRoot: "gpu"}},
+		},
+	}
+
+	setPlannedRebalance(config, "cluster-root", "gpu", "team-a",
+		v1.ResourceList{v1.ResourceCPU: resource.MustParse("8"), v1.ResourceMemory: resource.MustParse("8Gi"), v1.ResourcePods: resource.MustParse("50")},
+		v1.ResourceList{v1.ResourceCPU: resource.MustParse("6"), v1.ResourceMemory: resource.MustParse("8Gi"), v1.ResourcePods: resource.MustParse("50")})
+
+	if assert.Len(t, config.Status.Quotas[0].PlannedRebalances, 1) {
+		rebalance := config.Status.Quotas[0].PlannedRebalances[0]
+		assert.Equal(t, "team-a", rebalance.Subnamespace)
+		assert.True(t, resource.MustParse("8").Equal(rebalance.Current[v1.ResourceCPU]))
+		assert.True(t, resource.MustParse("6").Equal(rebalance.Quota[v1.ResourceCPU]))
+		assert.NotContains(t, rebalance.Quota, v1.ResourcePods)
+		assert.Len(t, rebalance.PlannedChange, 1)
+		assert.True(t, resource.MustParse("-2").Equal(rebalance.PlannedChange[v1.ResourceCPU]))
+	}
+}
+
+func TestRecordReservationEventDryRun(t *testing.T) {
+	config := &danav1alpha1.NodeQuotaConfig{Spec: danav1alpha1.NodeQuotaConfigSpec{Mode: danav1alpha1.ConfigModeDryRun}}
+	sns := &danav1.Subnamespace{ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-root", Name: "gpu"}}
+	recorder := record.NewFakeRecorder(10)
+
+	RecordReservationEvent(recorder, config, v1.EventTypeWarning, EventReasonReservationStarted, "reservation started", "gpu", sns)
+	assert.Empty(t, recorder.Events)
+
+	config.Spec.Mode = danav1alpha1.ConfigModeEnforce
+	RecordReservationEvent(recorder, config, v1.EventTypeWarning, EventReasonReservationStarted, "reservation started", "gpu", sns)
+	assert.Len(t, recorder.Events, 2)
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	danav1 "github.com/dana-team/hns/api/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+	nqsmetrics "github.com/dana-team/hns-nqs-plugin/internal/metrics"
+)
+
+// FieldManager is the field manager of the quota writes of the plugin.
+const FieldManager = "hns-nqs-plugin"
+
+// ReleasedFieldManager is the field manager which the plugin hands the resources which were removed from the controlled
+// resources off to, so they are left in the quotas instead of being removed by the next apply.
+const ReleasedFieldManager = "hns-nqs-plugin-released"
+
+// patchQuota gets the latest version of a root ResourceQuota or a secondary root Subnamespace, changes it with mutate
+// and writes only the fields which were changed with a merge patch. The patch is rejected when the object changed since
+// it was read, so mutate always works on the latest version, and the conflict is retried with backoff.
+// It takes the object with its namespace and name set, and the root and the secondary root of the quota for the metrics,
+// the secondary root is empty for the root itself.
+func patchQuota(ctx context.Context, c client.Client, object client.Object, mutate func(), rootNamespace, secondaryRoot string) error {
+	empty := object.DeepCopyObject()
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		// the object is reset before every attempt, so nothing of a rejected attempt is left in it
+		reflect.ValueOf(object).Elem().Set(reflect.ValueOf(empty.DeepCopyObject()).Elem())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+			return err
+		}
+		original := object.DeepCopyObject().(client.Object)
+		mutate()
+
+		err := c.Patch(ctx, object, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}), client.FieldOwner(FieldManager))
+		if errors.IsConflict(err) {
+			nqsmetrics.ObserveQuotaWriteConflict(rootNamespace, secondaryRoot)
+		}
+		return err
+	})
+	nqsmetrics.ObserveQuotaWrite(rootNamespace, secondaryRoot, err == nil)
+	return err
+}
+
+// FieldConflictError is returned when controlled resources of quotas could not be written, since they are owned by
+// other field managers.
+type FieldConflictError struct {
+	// Conflicts describes every quota with the conflicting fields and their managers.
+	Conflicts []string
+}
+
+// Error returns the descriptions of the conflicts.
+func (e *FieldConflictError) Error() string {
+	return strings.Join(e.Conflicts, "; ")
+}
+
+// FieldConflicts returns the descriptions of the conflicts of the error if it is a FieldConflictError, or nil otherwise.
+func FieldConflicts(err error) []string {
+	var conflictErr *FieldConflictError
+	if goerrors.As(err, &conflictErr) {
+		return conflictErr.Conflicts
+	}
+	return nil
+}
+
+// applyQuota writes the quota of the controlled resources of a root ResourceQuota or a Subnamespace with server-side apply
+// by the plugin field manager, so the plugin owns only these entries of the hard quota and the other entries can be owned
+// by other field managers. Entries which are owned by another field manager are taken over when force is set, or when
+// every conflicting manager is one whose changes are reverted, see canTakeOverFields, otherwise a FieldConflictError is
+// returned. Other conflicts, e.g. with a change of the object between reading and applying it, are retried with backoff.
+// The resources which the plugin applied before but are no longer controlled are handed off to the ReleasedFieldManager
+// with their current quantities first, since the apply would remove them from the quota otherwise.
+// It takes the object with its namespace and name set, which is set to its latest version before the apply, the quota
+// of the object which was last synced, or nil if it wasn't synced, a description of the object for the conflicts, and
+// the root and the secondary root of the quota for the metrics, the secondary root is empty for the root itself.
+func applyQuota(ctx context.Context, c client.Client, object client.Object, hard, synced v1.ResourceList, force bool, description, rootNamespace, secondaryRoot string) error {
+	empty := object.DeepCopyObject()
+	err := retry.OnError(retry.DefaultBackoff, isRetriableConflict, func() error {
+		// the object is reset before every attempt, so nothing of a rejected attempt is left in it
+		reflect.ValueOf(object).Elem().Set(reflect.ValueOf(empty.DeepCopyObject()).Elem())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+			return err
+		}
+		if released := releasedResources(object, hard); len(released) > 0 {
+			if err := c.Patch(ctx, quotaApplyObject(object, released), client.Apply, client.FieldOwner(ReleasedFieldManager)); err != nil {
+				return err
+			}
+		}
+		options := []client.PatchOption{client.FieldOwner(FieldManager)}
+		if force || canTakeOverFields(object, hard, synced) {
+			options = append(options, client.ForceOwnership)
+		}
+		err := c.Patch(ctx, quotaApplyObject(object, hard), client.Apply, options...)
+		if isRetriableConflict(err) {
+			nqsmetrics.ObserveQuotaWriteConflict(rootNamespace, secondaryRoot)
+		}
+		return err
+	})
+	if err == nil {
+		err = removeUnmanagedAnnotation(ctx, c, object)
+	}
+	nqsmetrics.ObserveQuotaWrite(rootNamespace, secondaryRoot, err == nil)
+
+	if conflicts := fieldManagerConflicts(err); len(conflicts) > 0 {
+		return &FieldConflictError{Conflicts: []string{fmt.Sprintf("%s: %s", description, strings.Join(conflicts, ", "))}}
+	}
+	return err
+}
+
+// canTakeOverFields checks if every controlled resource of the apply which would conflict with another field manager is
+// owned only by managers whose changes are reverted:
+//   - the updates of the plugin itself, which wrote the quotas with updates before it wrote them with server-side apply;
+//   - other updates, e.g. `kubectl edit`, of resources which were last synced by the plugin, which are manual changes;
+//   - the ReleasedFieldManager, which keeps the resources the plugin controlled before.
+//
+// Resources owned by other appliers, and resources changed by updates which the plugin never synced, e.g. of quotas
+// the plugin writes for the first time, are conflicts.
+func canTakeOverFields(object client.Object, hard, synced v1.ResourceList) bool {
+	current, path := quotaHard(object)
+	for resourceName, quantity := range hard {
+		// a resource which already has the applied quantity is shared with its other managers instead of conflicting
+		if currentQuantity, ok := current[resourceName]; ok && currentQuantity.Cmp(quantity) == 0 {
+			continue
+		}
+		_, wasSynced := synced[resourceName]
+		for _, managedFields := range object.GetManagedFields() {
+			if !ownsField(managedFields, append(path, string(resourceName))...) {
+				continue
+			}
+			if managedFields.Manager == ReleasedFieldManager || (managedFields.Manager == FieldManager && managedFields.Operation == metav1.ManagedFieldsOperationApply) {
+				continue
+			}
+			if managedFields.Operation != metav1.ManagedFieldsOperationUpdate || (managedFields.Manager != FieldManager && !wasSynced) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// releasedResources returns the current quantities of the resources of the quota which the plugin applied, but are not
+// in the applied quota anymore, since they were removed from the controlled resources.
+func releasedResources(object client.Object, hard v1.ResourceList) v1.ResourceList {
+	current, path := quotaHard(object)
+	released := v1.ResourceList{}
+	for resourceName, quantity := range current {
+		if _, ok := hard[resourceName]; ok {
+			continue
+		}
+		for _, managedFields := range object.GetManagedFields() {
+			if managedFields.Manager == FieldManager && managedFields.Operation == metav1.ManagedFieldsOperationApply && ownsField(managedFields, append(path, string(resourceName))...) {
+				released[resourceName] = quantity
+			}
+		}
+	}
+	return released
+}
+
+// quotaHard returns the hard quota of a root ResourceQuota or a Subnamespace, along with the path of the hard quota in
+// the object.
+func quotaHard(object client.Object) (v1.ResourceList, []string) {
+	if sns, ok := object.(*danav1.Subnamespace); ok {
+		return sns.Spec.ResourceQuotaSpec.Hard, []string{"spec", "resourcequota", "hard"}
+	}
+	if rq, ok := object.(*v1.ResourceQuota); ok {
+		return rq.Spec.Hard, []string{"spec", "hard"}
+	}
+	return nil, nil
+}
+
+// ownsField checks if the managed fields entry owns the field in the given path.
+func ownsField(managedFields metav1.ManagedFieldsEntry, path ...string) bool {
+	if managedFields.FieldsV1 == nil {
+		return false
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(managedFields.FieldsV1.Raw, &fields); err != nil {
+		return false
+	}
+	for _, name := range path {
+		next, ok := fields["f:"+name].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		fields = next
+	}
+	return true
+}
+
+// quotaApplyObject returns the apply configuration of a root ResourceQuota or a Subnamespace, which holds only the given
+// entries of its hard quota.
+func quotaApplyObject(object client.Object, hard v1.ResourceList) *unstructured.Unstructured {
+	hardEntries := map[string]interface{}{}
+	for resourceName, quantity := range hard {
+		hardEntries[string(resourceName)] = quantity.String()
+	}
+
+	applyObject := &unstructured.Unstructured{}
+	if _, ok := object.(*danav1.Subnamespace); ok {
+		applyObject.SetGroupVersionKind(danav1.GroupVersion.WithKind("Subnamespace"))
+		applyObject.Object["spec"] = map[string]interface{}{"resourcequota": map[string]interface{}{"hard": hardEntries}}
+	} else {
+		applyObject.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("ResourceQuota"))
+		applyObject.Object["spec"] = map[string]interface{}{"hard": hardEntries}
+	}
+	applyObject.SetNamespace(object.GetNamespace())
+	applyObject.SetName(object.GetName())
+	return applyObject
+}
+
+// isForcingFields checks if the ownership of controlled resources which are owned by other field managers is taken over.
+func isForcingFields(config danav1alpha1.NodeQuotaConfig) bool {
+	return config.Spec.FieldConflictPolicy == danav1alpha1.FieldConflictPolicyForce
+}
+
+// removeUnmanagedAnnotation removes the annotation which was set when the quota was orphaned by a deleted NodeQuotaConfig.
+// A copy of the object is patched, so the object is left as it was read before the quota was written.
+func removeUnmanagedAnnotation(ctx context.Context, c client.Client, object client.Object) error {
+	if _, ok := object.GetAnnotations()[danav1alpha1.UnmanagedAnnotation]; !ok {
+		return nil
+	}
+	patched := object.DeepCopyObject().(client.Object)
+	annotations := patched.GetAnnotations()
+	delete(annotations, danav1alpha1.UnmanagedAnnotation)
+	patched.SetAnnotations(annotations)
+	return c.Patch(ctx, patched, client.MergeFrom(object), client.FieldOwner(FieldManager))
+}
+
+// isRetriableConflict checks if the error is a conflict which may succeed when retried, which are all the conflicts except
+// for fields owned by other field managers.
+func isRetriableConflict(err error) bool {
+	return errors.IsConflict(err) && len(fieldManagerConflicts(err)) == 0
+}
+
+// fieldManagerConflicts returns the description of every field of the apply which is owned by another field manager,
+// with the path of the field and the conflicting manager, e.g. `.spec.hard.cpu (conflict with "kubectl-edit" using v1)`.
+func fieldManagerConflicts(err error) []string {
+	status, ok := err.(errors.APIStatus)
+	if !ok || !errors.IsConflict(err) || status.Status().Details == nil {
+		return nil
+	}
+
+	var conflicts []string
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", cause.Field, cause.Message))
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+	"github.com/dana-team/hns-nqs-plugin/internal/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// NodeQuotaConfigMutator records the user who requested to release or extend reservations on NodeQuotaConfig objects.
+type NodeQuotaConfigMutator struct {
+	Decoder admission.Decoder
+}
+
+// +kubebuilder:webhook:path=/mutate-v1alpha1-nodequotaconfig,mutating=true,failurePolicy=fail,sideEffects=None,groups=dana.hns.io,resources=nodequotaconfigs,verbs=create;update,versions=v1alpha1,name=mnodequotaconfig.dana.hns.io,admissionReviewVersions=v1
+
+// Handle implements the mutation webhook.
+func (m *NodeQuotaConfigMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	logger := log.FromContext(ctx).WithValues("webhook", "NodeQuotaConfig mutation Webhook", "Name", req.Name)
+	logger.Info("webhook request received")
+
+	config := &danav1alpha1.NodeQuotaConfig{}
+	if err := m.Decoder.DecodeRaw(req.Object, config); err != nil {
+		logger.Error(err, "failed to decode object", "request object", req.Object)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	oldConfig := &danav1alpha1.NodeQuotaConfig{}
+	if req.Operation == admissionv1.Update {
+		if err := m.Decoder.DecodeRaw(req.OldObject, oldConfig); err != nil {
+			logger.Error(err, "failed to decode old object", "request old object", req.OldObject)
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	requester := reservationRequester(oldConfig, config, req.UserInfo.Username)
+	if current, ok := config.Annotations[danav1alpha1.ReservationRequesterAnnotation]; current == requester && ok == (requester != "") {
+		return admission.Allowed("the reservation requester is up to date")
+	}
+
+	marshalConfig, err := m.UpdateRequester(config, requester)
+	if err != nil {
+		logger.Error(err, "failed to marshal object", "object", config)
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshalConfig)
+}
+
+// UpdateRequester sets the reservation requester annotation of the object, or removes it if the requester is empty.
+func (m *NodeQuotaConfigMutator) UpdateRequester(config *danav1alpha1.NodeQuotaConfig, requester string) ([]byte, error) {
+	if requester == "" {
+		delete(config.Annotations, danav1alpha1.ReservationRequesterAnnotation)
+	} else {
+		config.Annotations[danav1alpha1.ReservationRequesterAnnotation] = requester
+	}
+	return json.Marshal(config)
+}
+
+// reservationRequester returns the reservation requester annotation the object must have, so it can't be set by users:
+// the user who changed the reservation requests, the requester of the old object if the requests weren't changed,
+// or an empty string if the object has no reservation requests.
+func reservationRequester(oldConfig, config *danav1alpha1.NodeQuotaConfig, username string) string {
+	if !utils.HasReservationRequests(config.Annotations) {
+		return ""
+	}
+	if reservationRequestsChanged(oldConfig, config) {
+		return username
+	}
+	return oldConfig.Annotations[danav1alpha1.ReservationRequesterAnnotation]
+}
+
+// reservationRequestsChanged checks if the annotations requesting to release or extend reservations were changed.
+func reservationRequestsChanged(oldConfig, config *danav1alpha1.NodeQuotaConfig) bool {
+	for _, annotation := range []string{danav1alpha1.ReleaseReservationsAnnotation, danav1alpha1.ExtendReservationsAnnotation} {
+		if oldConfig.Annotations[annotation] != config.Annotations[annotation] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package webhooks
+
+import (
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+	"github.com/dana-team/hns-nqs-plugin/internal/utils"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/strings/slices"
+)
+
+// ValidateNodeQuotaConfig validates the spec and the reservation requests annotations of the given NodeQuotaConfig.
+// It returns a list of field-level errors, which is empty if the spec is valid.
+func ValidateNodeQuotaConfig(config *danav1alpha1.NodeQuotaConfig) field.ErrorList {
+	specPath := field.NewPath("spec")
+	var allErrs field.ErrorList
+
+	if config.Spec.ReservedHoursToLive < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("reservedHoursToLive"), config.Spec.ReservedHoursToLive, "must be greater than or equal to 0"))
+	}
+
+	if config.Spec.SettleWindow != nil && config.Spec.SettleWindow.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("settleWindow"), config.Spec.SettleWindow.Duration.String(), "must be greater than or equal to 0"))
+	}
+
+	allErrs = append(allErrs, validateRoots(config.Spec.Roots, config.Spec.ControlledResources, specPath.Child("subnamespacesRoots"))...)
+	allErrs = append(allErrs, validateAnnotations(config)...)
+	return allErrs
+}
+
+// ValidateNodeQuotaConfigUpdate validates an update of the given NodeQuotaConfig.
+// A NodeQuotaConfig which is being deleted isn't validated, so its finalizer can always be removed, and when the spec
+// didn't change only the annotations are validated, so NodeQuotaConfigs which were created before the webhook and are
+// invalid can still be annotated.
+func ValidateNodeQuotaConfigUpdate(oldConfig, config *danav1alpha1.NodeQuotaConfig) field.ErrorList {
+	if config.DeletionTimestamp != nil {
+		return nil
+	}
+	if equality.Semantic.DeepEqual(oldConfig.Spec, config.Spec) {
+		return validateAnnotations(config)
+	}
+	return ValidateNodeQuotaConfig(config)
+}
+
+// validateAnnotations validates the reservation requests and the pause annotations of the NodeQuotaConfig.
+func validateAnnotations(config *danav1alpha1.NodeQuotaConfig) field.ErrorList {
+	var allErrs field.ErrorList
+	if _, err := utils.ParseReservationRequests(config.Annotations); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "annotations"), config.Annotations, err.Error()))
+	}
+	allErrs = append(allErrs, validatePauseAnnotations(config)...)
+	return allErrs
+}
+
+// validatePauseAnnotations validates that the pause annotations are well-formed and pause only node groups of the NodeQuotaConfig.
+func validatePauseAnnotations(config *danav1alpha1.NodeQuotaConfig) field.ErrorList {
+	annotationsPath := field.NewPath("metadata", "annotations")
+	_, pausedNodeGroups, err := utils.ParsePauseAnnotations(config.Annotations)
+	if err != nil {
+		return field.ErrorList{field.Invalid(annotationsPath.Key(danav1alpha1.PausedAnnotation), config.Annotations[danav1alpha1.PausedAnnotation], err.Error())}
+	}
+
+	var nodeGroups []string
+	for _, root := range config.Spec.Roots {
+		for _, secondaryRoot := range root.SecondaryRoots {
+			nodeGroups = append(nodeGroups, secondaryRoot.Name)
+		}
+	}
+	var allErrs field.ErrorList
+	for _, nodeGroup := range pausedNodeGroups {
+		if !slices.Contains(nodeGroups, nodeGroup) {
+			allErrs = append(allErrs, field.NotFound(annotationsPath.Key(danav1alpha1.PausedNodeGroupsAnnotation), nodeGroup))
+		}
+	}
+	return allErrs
+}
+
+// ValidateConfigClaims validates that the NodeQuotaConfig doesn't claim roots or secondary roots which are managed by
+// other NodeQuotaConfigs. It takes the conflicts of the NodeQuotaConfig, and returns an error for every conflicting field.
+func ValidateConfigClaims(config *danav1alpha1.NodeQuotaConfig, conflicts []danav1alpha1.ConfigConflict) field.ErrorList {
+	rootsPath := field.NewPath("spec", "subnamespacesRoots")
+	var allErrs field.ErrorList
+	for _, conflict := range conflicts {
+		for i, root := range config.Spec.Roots {
+			if root.RootNamespace != conflict.RootNamespace {
+				continue
+			}
+			if conflict.SecondaryRoot == "" {
+				allErrs = append(allErrs, field.Forbidden(rootsPath.Index(i).Child("rootNamespace"), utils.DescribeConflict(conflict)))
+				continue
+			}
+			for j, secondaryRoot := range root.SecondaryRoots {
+				if secondaryRoot.Name == conflict.SecondaryRoot {
+					allErrs = append(allErrs, field.Forbidden(rootsPath.Index(i).Child("secondaryRoots").Index(j).Child("name"), utils.DescribeConflict(conflict)))
+				}
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateRoots validates the roots of the NodeQuotaConfig and the secondary roots under them.
+// Secondary root names must be unique across all roots, since node groups are looked up by name.
+func validateRoots(roots []danav1alpha1.SubnamespacesRoots, controlledResources []string, rootsPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	rootNames := map[string]bool{}
+	secondaryRootNames := map[string]bool{}
+	hasCatchAll := false
+
+	for i, root := range roots {
+		rootPath := rootsPath.Index(i)
+		if root.RootNamespace == "" {
+			allErrs = append(allErrs, field.Required(rootPath.Child("rootNamespace"), "root namespace name must be set"))
+		} else if rootNames[root.RootNamespace] {
+			allErrs = append(allErrs, field.Duplicate(rootPath.Child("rootNamespace"), root.RootNamespace))
+		}
+		rootNames[root.RootNamespace] = true
+
+		for j, secondaryRoot := range root.SecondaryRoots {
+			secondaryRootPath := rootPath.Child("secondaryRoots").Index(j)
+			if secondaryRoot.Name == "" {
+				allErrs = append(allErrs, field.Required(secondaryRootPath.Child("name"), "secondary root name must be set"))
+			} else if secondaryRootNames[secondaryRoot.Name] {
+				allErrs = append(allErrs, field.Duplicate(secondaryRootPath.Child("name"), secondaryRoot.Name))
+			}
+			secondaryRootNames[secondaryRoot.Name] = true
+
+			if secondaryRoot.CatchAll {
+				if hasCatchAll {
+					allErrs = append(allErrs, field.Forbidden(secondaryRootPath.Child("catchAll"), "only one node group may be a catch-all node group"))
+				}
+				hasCatchAll = true
+			}
+
+			allErrs = append(allErrs, validateNodeGroup(secondaryRoot, controlledResources, secondaryRootPath)...)
+		}
+	}
+	return allErrs
+}
+
+// validateNodeGroup validates the label selector, multipliers, rounding modes, eligibility rules, rebalance policy and system resource claim of a single node group.
+func validateNodeGroup(nodeGroup danav1alpha1.NodeGroup, controlledResources []string, nodeGroupPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	nodeSelectorPath := nodeGroupPath.Child("nodeSelector")
+	if nodeGroup.CatchAll {
+		if len(nodeGroup.LabelSelector) > 0 {
+			allErrs = append(allErrs, field.Forbidden(nodeGroupPath.Child("labelSelector"), "may not be set for a catch-all node group"))
+		}
+		if nodeGroup.NodeSelector != nil {
+			allErrs = append(allErrs, field.Forbidden(nodeSelectorPath, "may not be set for a catch-all node group"))
+		}
+	} else if len(nodeGroup.LabelSelector) == 0 && isEmptyLabelSelector(nodeGroup.NodeSelector) {
+		allErrs = append(allErrs, field.Required(nodeGroupPath.Child("labelSelector"), "either labelSelector or nodeSelector must not be empty"))
+	}
+	allErrs = append(allErrs, metav1validation.ValidateLabelSelector(nodeGroup.NodeSelector, metav1validation.LabelSelectorValidationOptions{}, nodeSelectorPath)...)
+
+	multipliersPath := nodeGroupPath.Child("multipliers")
+	for resourceName, value := range nodeGroup.ResourceMultiplier {
+		if !slices.Contains(controlledResources, resourceName) {
+			allErrs = append(allErrs, field.NotSupported(multipliersPath.Key(resourceName), resourceName, controlledResources))
+		}
+		multiplier, err := utils.ParseMultiplier(value)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(multipliersPath.Key(resourceName), value, "must be a valid decimal number"))
+		} else if multiplier.Sign() <= 0 {
+			allErrs = append(allErrs, field.Invalid(multipliersPath.Key(resourceName), value, "must be greater than 0"))
+		}
+	}
+
+	roundingModesPath := nodeGroupPath.Child("roundingModes")
+	for resourceName := range nodeGroup.RoundingModes {
+		if !slices.Contains(controlledResources, resourceName) {
+			allErrs = append(allErrs, field.NotSupported(roundingModesPath.Key(resourceName), resourceName, controlledResources))
+		}
+	}
+
+	if nodeGroup.Eligibility != nil {
+		eligibilityPath := nodeGroupPath.Child("eligibility")
+		for i, rule := range nodeGroup.Eligibility.ExcludeConditions {
+			if rule.Type == "" {
+				allErrs = append(allErrs, field.Required(eligibilityPath.Child("excludeConditions").Index(i).Child("type"), "condition type must be set"))
+			}
+		}
+		for i, rule := range nodeGroup.Eligibility.ExcludeTaints {
+			if rule.Key == "" {
+				allErrs = append(allErrs, field.Required(eligibilityPath.Child("excludeTaints").Index(i).Child("key"), "taint key must be set"))
+			}
+		}
+	}
+
+	if nodeGroup.Rebalance != nil {
+		weightsPath := nodeGroupPath.Child("rebalance", "weights")
+		if len(nodeGroup.Rebalance.Weights) > 0 && nodeGroup.Rebalance.Strategy != danav1alpha1.RebalanceStrategyWeighted {
+			allErrs = append(allErrs, field.Forbidden(weightsPath, "weights may only be set with the Weighted strategy"))
+		}
+		for name, weight := range nodeGroup.Rebalance.Weights {
+			if weight < 0 {
+				allErrs = append(allErrs, field.Invalid(weightsPath.Key(name), weight, "must be greater than or equal to 0"))
+			}
+		}
+	}
+
+	claimPath := nodeGroupPath.Child("systemResourceClaim")
+	for resourceName, quantity := range nodeGroup.SystemResourceClaim {
+		if !slices.Contains(controlledResources, resourceName) {
+			allErrs = append(allErrs, field.NotSupported(claimPath.Key(resourceName), resourceName, controlledResources))
+		}
+		if quantity.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(claimPath.Key(resourceName), quantity.String(), "must be greater than or equal to 0"))
+		}
+	}
+	return allErrs
+}
+
+// isEmptyLabelSelector checks if the given label selector has neither matchLabels nor matchExpressions.
+func isEmptyLabelSelector(selector *metav1.LabelSelector) bool {
+	return selector == nil || (len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0)
+}
@@ -0,0 +1,280 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func newTestConfig() *danav1alpha1.NodeQuotaConfig {
+	return &danav1alpha1.NodeQuotaConfig{
+		Spec: danav1alpha1.NodeQuotaConfigSpec{
+			ReservedHoursToLive: 24,
+			ControlledResources: []string{"cpu", "memory"},
+			Roots: []danav1alpha1.SubnamespacesRoots{
+				{
+					RootNamespace: "cluster-root",
+					SecondaryRoots: []danav1alpha1.NodeGroup{
+						{
+							Name:                "gpu",
+							LabelSelector:       map[string]string{"app": "gpu"},
+							ResourceMultiplier:  map[string]string{"cpu": "2"},
+							SystemResourceClaim: map[string]resource.Quantity{"memory": resource.MustParse("1Gi")},
+						},
+						{
+							Name:          "cpu-workloads",
+							LabelSelector: map[string]string{"app": "cpu-workloads"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateNodeQuotaConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		mutate        func(config *danav1alpha1.NodeQuotaConfig)
+		expectedField string
+		expectedType  field.ErrorType
+	}{
+		{
+			name:   "valid config",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {},
+		},
+		{
+			name: "negative reservedHoursToLive",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.ReservedHoursToLive = -1
+			},
+			expectedField: "spec.reservedHoursToLive",
+			expectedType:  field.ErrorTypeInvalid,
+		},
+		{
+			name: "misspelled multiplier",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[0].ResourceMultiplier["cpu"] = "2x"
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[0].multipliers[cpu]",
+			expectedType:  field.ErrorTypeInvalid,
+		},
+		{
+			name: "multiplier for uncontrolled resource",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[0].ResourceMultiplier = map[string]string{"pods": "2"}
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[0].multipliers[pods]",
+			expectedType:  field.ErrorTypeNotSupported,
+		},
+		{
+			name: "system resource claim for uncontrolled resource",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[0].SystemResourceClaim = map[string]resource.Quantity{"pods": resource.MustParse("10")}
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[0].systemResourceClaim[pods]",
+			expectedType:  field.ErrorTypeNotSupported,
+		},
+		{
+			name: "duplicate secondary root names",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[1].Name = "gpu"
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[1].name",
+			expectedType:  field.ErrorTypeDuplicate,
+		},
+		{
+			name: "empty label selector",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[1].LabelSelector = map[string]string{}
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[1].labelSelector",
+			expectedType:  field.ErrorTypeRequired,
+		},
+		{
+			name: "node selector instead of label selector",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[1].LabelSelector = nil
+				config.Spec.Roots[0].SecondaryRoots[1].NodeSelector = &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+					},
+				}
+			},
+		},
+		{
+			name: "invalid node selector operator",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[1].NodeSelector = &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "gpu", Operator: "Equals", Values: []string{"a100"}},
+					},
+				}
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[1].nodeSelector.matchExpressions[0].operator",
+			expectedType:  field.ErrorTypeInvalid,
+		},
+		{
+			name: "taint rule without key",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[0].Eligibility = &danav1alpha1.NodeEligibility{
+					ExcludeUnschedulable: true,
+					ExcludeTaints:        []danav1alpha1.TaintRule{{Value: "maintenance"}},
+				}
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[0].eligibility.excludeTaints[0].key",
+			expectedType:  field.ErrorTypeRequired,
+		},
+		{
+			name: "catch-all node group",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[1].LabelSelector = nil
+				config.Spec.Roots[0].SecondaryRoots[1].CatchAll = true
+			},
+		},
+		{
+			name: "catch-all node group with a label selector",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[1].CatchAll = true
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[1].labelSelector",
+			expectedType:  field.ErrorTypeForbidden,
+		},
+		{
+			name: "negative settle window",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.SettleWindow = &metav1.Duration{Duration: -time.Minute}
+			},
+			expectedField: "spec.settleWindow",
+			expectedType:  field.ErrorTypeInvalid,
+		},
+		{
+			name: "negative rebalance weight",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Spec.Roots[0].SecondaryRoots[0].Rebalance = &danav1alpha1.RebalancePolicy{
+					Strategy: danav1alpha1.RebalanceStrategyWeighted,
+					Weights:  map[string]int32{"team-a": 2, "team-b": -1},
+				}
+			},
+			expectedField: "spec.subnamespacesRoots[0].secondaryRoots[0].rebalance.weights[team-b]",
+			expectedType:  field.ErrorTypeInvalid,
+		},
+		{
+			name: "valid reservation requests",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Annotations = map[string]string{
+					danav1alpha1.ReleaseReservationsAnnotation: "gpu/worker-3",
+					danav1alpha1.ExtendReservationsAnnotation:  "cpu-workloads=12h",
+				}
+			},
+		},
+		{
+			name: "malformed extend reservations annotation",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Annotations = map[string]string{danav1alpha1.ExtendReservationsAnnotation: "gpu=12 hours"}
+			},
+			expectedField: "metadata.annotations",
+			expectedType:  field.ErrorTypeInvalid,
+		},
+		{
+			name: "paused node group",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Annotations = map[string]string{danav1alpha1.PausedNodeGroupsAnnotation: config.Spec.Roots[0].SecondaryRoots[0].Name}
+			},
+		},
+		{
+			name: "malformed paused annotation",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Annotations = map[string]string{danav1alpha1.PausedAnnotation: "yes"}
+			},
+			expectedField: "metadata.annotations[dana.hns.io/paused]",
+			expectedType:  field.ErrorTypeInvalid,
+		},
+		{
+			name: "paused unknown node group",
+			mutate: func(config *danav1alpha1.NodeQuotaConfig) {
+				config.Annotations = map[string]string{danav1alpha1.PausedNodeGroupsAnnotation: "storage"}
+			},
+			expectedField: "metadata.annotations[dana.hns.io/paused-node-groups]",
+			expectedType:  field.ErrorTypeNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			tt.mutate(config)
+
+			errs := ValidateNodeQuotaConfig(config)
+			if tt.expectedField == "" {
+				assert.Empty(t, errs)
+				return
+			}
+			if assert.Len(t, errs, 1) {
+				assert.Equal(t, tt.expectedField, errs[0].Field)
+				assert.Equal(t, tt.expectedType, errs[0].Type)
+			}
+		})
+	}
+}
+
+func TestValidateConfigClaims(t *testing.T) {
+	config := newTestConfig()
+	conflicts := []danav1alpha1.ConfigConflict{{RootNamespace: config.Spec.Roots[0].RootNamespace, SecondaryRoot: config.Spec.Roots[0].SecondaryRoots[1].Name, ManagedBy: "default/other"}}
+
+	errs := ValidateConfigClaims(config, conflicts)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.subnamespacesRoots[0].secondaryRoots[1].name", errs[0].Field)
+		assert.Equal(t, field.ErrorTypeForbidden, errs[0].Type)
+	}
+	assert.Empty(t, ValidateConfigClaims(config, nil))
+}
+
+func TestValidateNodeQuotaConfigUpdate(t *testing.T) {
+	oldConfig := newTestConfig()
+	oldConfig.Spec.ReservedHoursToLive = -1
+
+	// only the metadata of a config which was created before the webhook changed
+	config := oldConfig.DeepCopy()
+	config.Finalizers = []string{danav1alpha1.NodeQuotaConfigFinalizer}
+	assert.Empty(t, ValidateNodeQuotaConfigUpdate(oldConfig, config))
+
+	config.Annotations = map[string]string{danav1alpha1.PausedAnnotation: "yes"}
+	if errs := ValidateNodeQuotaConfigUpdate(oldConfig, config); assert.Len(t, errs, 1) {
+		assert.Equal(t, field.ErrorTypeInvalid, errs[0].Type)
+	}
+
+	// the finalizer of a config which is being deleted can always be removed
+	now := metav1.Now()
+	config.DeletionTimestamp = &now
+	config.Finalizers = nil
+	assert.Empty(t, ValidateNodeQuotaConfigUpdate(oldConfig, config))
+
+	config = oldConfig.DeepCopy()
+	config.Spec.ControlledResources = append(config.Spec.ControlledResources, "pods")
+	if errs := ValidateNodeQuotaConfigUpdate(oldConfig, config); assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.reservedHoursToLive", errs[0].Field)
+	}
+}
+
+func TestReservationRequester(t *testing.T) {
+	oldConfig := newTestConfig()
+	config := newTestConfig()
+
+	// the requester of new reservation requests is the user who made them
+	config.Annotations = map[string]string{danav1alpha1.ReleaseReservationsAnnotation: "gpu/node-1", danav1alpha1.ReservationRequesterAnnotation: "someone-else"}
+	assert.Equal(t, "alice", reservationRequester(oldConfig, config, "alice"))
+
+	// the requester can't be changed without changing the requests
+	oldConfig.Annotations = map[string]string{danav1alpha1.ReleaseReservationsAnnotation: "gpu/node-1", danav1alpha1.ReservationRequesterAnnotation: "alice"}
+	assert.Equal(t, "alice", reservationRequester(oldConfig, config, "bob"))
+
+	// the requester can't be set without requests
+	config.Annotations = map[string]string{danav1alpha1.ReservationRequesterAnnotation: "someone-else"}
+	assert.Equal(t, "", reservationRequester(oldConfig, config, "bob"))
+}
@@ -0,0 +1,93 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+
+	danav1alpha1 "github.com/dana-team/hns-nqs-plugin/api/v1alpha1"
+	"github.com/dana-team/hns-nqs-plugin/internal/utils"
+	"golang.org/x/exp/slices"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// NodeQuotaConfigValidator validates NodeQuotaConfig objects before they reach the reconciler.
+type NodeQuotaConfigValidator struct {
+	Client  client.Client
+	Decoder admission.Decoder
+}
+
+// +kubebuilder:webhook:path=/validate-v1alpha1-nodequotaconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=dana.hns.io,resources=nodequotaconfigs,verbs=create;update,versions=v1alpha1,name=nodequotaconfig.dana.hns.io,admissionReviewVersions=v1
+
+// Handle implements the validation webhook.
+func (v *NodeQuotaConfigValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	logger := log.FromContext(ctx).WithValues("webhook", "NodeQuotaConfig Webhook", "Name", req.Name)
+	logger.Info("webhook request received")
+
+	config := &danav1alpha1.NodeQuotaConfig{}
+	if err := v.Decoder.DecodeRaw(req.Object, config); err != nil {
+		logger.Error(err, "failed to decode object", "request object", req.Object)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	var errs field.ErrorList
+	if req.Operation != admissionv1.Update {
+		errs = ValidateNodeQuotaConfig(config)
+	} else {
+		oldConfig := &danav1alpha1.NodeQuotaConfig{}
+		if err := v.Decoder.DecodeRaw(req.OldObject, oldConfig); err != nil {
+			logger.Error(err, "failed to decode old object", "request old object", req.OldObject)
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		errs = ValidateNodeQuotaConfigUpdate(oldConfig, config)
+	}
+	if len(errs) > 0 {
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	// a NodeQuotaConfig which is being deleted doesn't write any quota, so its claims aren't validated either
+	if config.DeletionTimestamp != nil {
+		return admission.Allowed("the NodeQuotaConfig is being deleted")
+	}
+
+	conflicts, err := v.newConflicts(ctx, req, config)
+	if err != nil {
+		logger.Error(err, "failed to find conflicting NodeQuotaConfigs")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if errs := ValidateConfigClaims(config, conflicts); len(errs) > 0 {
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+
+	return admission.Allowed("all validations passed")
+}
+
+// newConflicts finds the roots and secondary roots of the NodeQuotaConfig which are managed by other NodeQuotaConfigs.
+// On update, only the conflicts which the update adds are returned, so NodeQuotaConfigs which are already conflicted
+// can still be updated, e.g. to remove the conflicting roots.
+func (v *NodeQuotaConfigValidator) newConflicts(ctx context.Context, req admission.Request, config *danav1alpha1.NodeQuotaConfig) ([]danav1alpha1.ConfigConflict, error) {
+	configList := danav1alpha1.NodeQuotaConfigList{}
+	if err := v.Client.List(ctx, &configList); err != nil {
+		return nil, err
+	}
+	conflicts := utils.ConfigConflicts(*config, configList.Items)
+	if req.Operation != admissionv1.Update || len(conflicts) == 0 {
+		return conflicts, nil
+	}
+
+	oldConfig := &danav1alpha1.NodeQuotaConfig{}
+	if err := v.Decoder.DecodeRaw(req.OldObject, oldConfig); err != nil {
+		return nil, err
+	}
+	oldConflicts := utils.ConfigConflicts(*oldConfig, configList.Items)
+
+	var added []danav1alpha1.ConfigConflict
+	for _, conflict := range conflicts {
+		if !slices.Contains(oldConflicts, conflict) {
+			added = append(added, conflict)
+		}
+	}
+	return added, nil
+}